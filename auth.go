@@ -0,0 +1,110 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/smithy-go/auth/bearer"
+)
+
+// APIKeyEnv is the environment variable holding a Bedrock API key.
+const APIKeyEnv = "AWS_BEARER_TOKEN_BEDROCK"
+
+// MaxShortTermAPIKeyDuration is the longest validity of a short-term Bedrock API key.
+const MaxShortTermAPIKeyDuration = 12 * time.Hour
+
+const (
+	apiKeyPrefix     = "bedrock-api-key-"
+	apiKeyVersion    = "&Version=1"
+	apiKeyURL        = "https://bedrock.amazonaws.com/?Action=CallWithBearerToken"
+	bearerAuthScheme = "httpBearerAuth"
+	sigV4AuthScheme  = "sigv4"
+
+	// SHA-256 of an empty payload
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// apiKey returns the API key used for bearer token auth, or "" to sign requests with AWS
+// credentials. The environment variable is ignored when credentials are configured explicitly.
+func (b *Bedrock) apiKey() string {
+	if b.APIKey != "" {
+		return b.APIKey
+	}
+	if b.StaticCredentials != nil || b.Anonymous {
+		return ""
+	}
+	return os.Getenv(APIKeyEnv)
+}
+
+// bearerToken returns the token provider for the API key, or nil without one.
+func (b *Bedrock) bearerToken() bearer.TokenProvider {
+	key := b.apiKey()
+	if key == "" {
+		return nil
+	}
+	return bearer.StaticTokenProvider{Token: bearer.Token{Value: key}}
+}
+
+// NewShortTermAPIKey mints a short-term Bedrock API key from the credentials and region in cfg.
+// The key is valid for expiresIn (default and maximum: 12h), or until the credentials expire if
+// that is sooner. It can be used as Bedrock.APIKey or AWS_BEARER_TOKEN_BEDROCK.
+func NewShortTermAPIKey(ctx context.Context, cfg aws.Config, expiresIn time.Duration) (string, error) {
+	if expiresIn == 0 {
+		expiresIn = MaxShortTermAPIKeyDuration
+	}
+	if expiresIn < time.Second || expiresIn > MaxShortTermAPIKeyDuration {
+		return "", fmt.Errorf("bedrock: API key duration %s must be between 1s and %s", expiresIn, MaxShortTermAPIKeyDuration)
+	}
+	if cfg.Region == "" {
+		return "", errors.New("bedrock: a region is required to create an API key")
+	}
+	if cfg.Credentials == nil {
+		return "", errors.New("bedrock: credentials are required to create an API key")
+	}
+
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("bedrock: failed to retrieve credentials: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiKeyURL, nil)
+	if err != nil {
+		return "", err
+	}
+	query := req.URL.Query()
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expiresIn.Seconds())))
+	req.URL.RawQuery = query.Encode()
+
+	presigned, _, err := v4.NewSigner().PresignHTTP(ctx, creds, req, emptyPayloadHash, "bedrock", cfg.Region, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("bedrock: failed to sign API key: %w", err)
+	}
+
+	token := strings.TrimPrefix(presigned, "https://") + apiKeyVersion
+	return apiKeyPrefix + base64.StdEncoding.EncodeToString([]byte(token)), nil
+}
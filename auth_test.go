@@ -0,0 +1,126 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+)
+
+// newConverseServer returns an HTTPS Converse endpoint that records the Authorization header of each request.
+func newConverseServer(t *testing.T, authorization *[]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*authorization = append(*authorization, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"output":{"message":{"role":"assistant","content":[{"text":"ok"}]}},` +
+			`"stopReason":"end_turn","usage":{"inputTokens":1,"outputTokens":1,"totalTokens":2}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAPIKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		plugin *bedrock.Bedrock
+		env    string
+		want   string
+	}{
+		{"field", &bedrock.Bedrock{APIKey: "field-key"}, "env-key", "Bearer field-key"},
+		{"environment", &bedrock.Bedrock{}, "env-key", "Bearer env-key"},
+		{
+			"static credentials ignore the environment",
+			&bedrock.Bedrock{StaticCredentials: &bedrock.StaticCredentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}},
+			"env-key",
+			"AWS4-HMAC-SHA256 Credential=AKID/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(bedrock.APIKeyEnv, tt.env)
+			var authorization []string
+			server := newConverseServer(t, &authorization)
+
+			b := tt.plugin
+			b.BaseEndpoint = server.URL
+			b.HTTPClient = server.Client()
+			g := newTestPlugin(t, b)
+			m := b.DefineModel(g, bedrock.ModelDefinition{Name: testModel, Type: "chat"}, nil)
+
+			if _, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("hi")); err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			if len(authorization) != 1 || !strings.HasPrefix(authorization[0], tt.want) {
+				t.Errorf("Authorization headers = %q, want %q", authorization, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewShortTermAPIKey(t *testing.T) {
+	cfg := aws.Config{
+		Region:      "us-west-2",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", "TOKEN"),
+	}
+	key, err := bedrock.NewShortTermAPIKey(context.Background(), cfg, time.Hour)
+	if err != nil {
+		t.Fatalf("NewShortTermAPIKey: %v", err)
+	}
+
+	encoded, ok := strings.CutPrefix(key, "bedrock-api-key-")
+	if !ok {
+		t.Fatalf("key %q does not have the bedrock-api-key- prefix", key)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("decoding key: %v", err)
+	}
+	u, err := url.Parse("https://" + string(decoded))
+	if err != nil {
+		t.Fatalf("parsing presigned URL: %v", err)
+	}
+	query := u.Query()
+	if u.Host != "bedrock.amazonaws.com" || query.Get("Action") != "CallWithBearerToken" || query.Get("Version") != "1" {
+		t.Errorf("presigned URL = %s, want a CallWithBearerToken request", decoded)
+	}
+	if got := query.Get("X-Amz-Expires"); got != "3600" {
+		t.Errorf("X-Amz-Expires = %q, want 3600", got)
+	}
+	if got := query.Get("X-Amz-Credential"); !strings.HasPrefix(got, "AKID/") || !strings.Contains(got, "/us-west-2/bedrock/") {
+		t.Errorf("X-Amz-Credential = %q, want AKID scoped to us-west-2 bedrock", got)
+	}
+	if query.Get("X-Amz-Security-Token") != "TOKEN" {
+		t.Error("presigned URL does not include the session token")
+	}
+
+	if _, err := bedrock.NewShortTermAPIKey(context.Background(), cfg, 13*time.Hour); err == nil {
+		t.Error("NewShortTermAPIKey accepted a duration over 12h")
+	}
+}
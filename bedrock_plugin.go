@@ -25,32 +25,35 @@ package bedrock
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"slices"
+	"log/slog"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	awsbedrock "github.com/aws/aws-sdk-go-v2/service/bedrock"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	smithydoc "github.com/aws/smithy-go/document"
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/core/api"
 	"github.com/firebase/genkit/go/genkit"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Type aliases for better readability
 type (
-	BedrockClient = *bedrockruntime.Client
-	Role          = ai.Role
-	ToolChoice    = string
-	FinishReason  = string
+	Role         = ai.Role
+	ToolChoice   = string
+	FinishReason = string
 )
 
 // Constants
@@ -80,106 +83,91 @@ const (
 	FinishReasonUnknown FinishReason = "unknown"
 )
 
-const bedrockCachePointTypeKey = "bedrockCachePointType"
-
-var (
-	// Models that support images/multimodal inputs
-	multimodalModels = []string{
-		// Anthropic Claude 3/3.5/3.7 models
-		"anthropic.claude-3-haiku-20240307-v1:0",
-		"anthropic.claude-3-sonnet-20240229-v1:0",
-		"anthropic.claude-3-opus-20240229-v1:0",
-		"anthropic.claude-3-5-sonnet-20240620-v1:0",
-		"anthropic.claude-3-5-sonnet-20241022-v2:0",
-		"anthropic.claude-3-7-sonnet-20250219-v1:0",
-		// Anthropic Claude 4 models
-		"anthropic.claude-opus-4-20250514-v1:0",
-		"anthropic.claude-sonnet-4-20250514-v1:0",
-		// Amazon Nova models (multimodal: text, image)
-		"amazon.nova-lite-v1:0",
-		"amazon.nova-pro-v1:0",
-		"amazon.nova-premier-v1:0",
-		// Meta Llama multimodal models
-		"meta.llama3-2-11b-instruct-v1:0",
-		"meta.llama3-2-90b-instruct-v1:0",
-		"meta.llama4-maverick-17b-instruct-v1:0",
-		"meta.llama4-scout-17b-instruct-v1:0",
-		// Mistral multimodal models
-		"mistral.pixtral-large-2502-v1:0",
-	}
-
-	// Models that support function calling/tools
-	toolSupportedModels = []string{
-		// Anthropic Claude 3/3.5/3.7 models
-		"anthropic.claude-3-haiku-20240307-v1:0",
-		"anthropic.claude-3-sonnet-20240229-v1:0",
-		"anthropic.claude-3-opus-20240229-v1:0",
-		"anthropic.claude-3-5-haiku-20241022-v1:0",
-		"anthropic.claude-3-5-sonnet-20240620-v1:0",
-		"anthropic.claude-3-5-sonnet-20241022-v2:0",
-		"anthropic.claude-3-7-sonnet-20250219-v1:0",
-		// Anthropic Claude 4 models
-		"anthropic.claude-opus-4-20250514-v1:0",
-		"anthropic.claude-sonnet-4-20250514-v1:0",
-		// Amazon Nova models
-		"amazon.nova-micro-v1:0",
-		"amazon.nova-lite-v1:0",
-		"amazon.nova-pro-v1:0",
-		"amazon.nova-premier-v1:0",
-		// Cohere Command models
-		"cohere.command-r-v1:0",
-		"cohere.command-r-plus-v1:0",
-		// Mistral models
-		"mistral.mistral-large-2402-v1:0",
-		"mistral.mistral-large-2407-v1:0",
-		"mistral.mistral-small-2402-v1:0",
-		"mistral.pixtral-large-2502-v1:0",
-		// AI21 Labs Jamba models
-		"ai21.jamba-1-5-large-v1:0",
-		"ai21.jamba-1-5-mini-v1:0",
-		// Meta Llama models
-		"meta.llama3-8b-instruct-v1:0",
-		"meta.llama3-70b-instruct-v1:0",
-		"meta.llama3-1-8b-instruct-v1:0",
-		"meta.llama3-1-70b-instruct-v1:0",
-		"meta.llama3-1-405b-instruct-v1:0",
-		"meta.llama3-2-1b-instruct-v1:0",
-		"meta.llama3-2-3b-instruct-v1:0",
-		"meta.llama3-2-11b-instruct-v1:0",
-		"meta.llama3-2-90b-instruct-v1:0",
-		"meta.llama3-3-70b-instruct-v1:0",
-		"meta.llama4-maverick-17b-instruct-v1:0",
-		"meta.llama4-scout-17b-instruct-v1:0",
-		// DeepSeek models
-		"deepseek.r1-v1:0",
-		// Writer models
-		"writer.palmyra-x4-v1:0",
-		"writer.palmyra-x5-v1:0",
-		// TwelveLabs models
-		"twelvelabs.pegasus-1-2-v1:0",
-	}
-)
-
 // Bedrock provides configuration options for the AWS Bedrock plugin.
 type Bedrock struct {
+	Namespace      string        // Plugin name and model namespace, e.g. "bedrock-eu" (default: "bedrock")
 	Region         string        // AWS region (optional, uses AWS_REGION or us-east-1)
 	MaxRetries     int           // Maximum number of retries (default: 3)
 	RequestTimeout time.Duration // Request timeout (default: 30s)
 	AWSConfig      *aws.Config   // Custom AWS config (optional)
-
-	mu      sync.Mutex // Mutex to control access
-	client  BedrockClient
-	initted bool // Whether the plugin has been initialized
+	Client         BedrockClient // Runtime client to use instead of one created from the AWS config (optional)
+	ModelListTTL   time.Duration // How long the foundation model listing is cached (default: 1h)
+	TenantCacheTTL time.Duration // How long unused tenant role credentials are cached (default: 1h)
+
+	// RequestRoleARNs lists the roles the "roleArn" request config key may assume, as exact
+	// ARNs or patterns ending with "*". Anyone who can set a request config can act as these
+	// roles, so only list roles meant for untrusted callers (default: none, the key is rejected).
+	RequestRoleARNs []string
+
+	BaseEndpoint      string             // Runtime endpoint URL, e.g. a VPC interface endpoint or local emulator (optional)
+	UseFIPS           bool               // Use FIPS endpoints (default: false)
+	UseDualStack      bool               // Use dual-stack IPv4/IPv6 endpoints (default: false)
+	HTTPClient        aws.HTTPClient     // HTTP client for AWS calls (default: AWS SDK client)
+	ProxyURL          string             // Proxy for AWS calls, e.g. "http://proxy:3128" (default: HTTPS_PROXY/HTTP_PROXY)
+	StaticCredentials *StaticCredentials // Credentials used instead of the default AWS credential chain (optional)
+	APIKey            string             // Bedrock API key for bearer token auth (default: AWS_BEARER_TOKEN_BEDROCK)
+	Anonymous         bool               // Send unsigned requests, e.g. to a local emulator (default: false)
+
+	Regions        []RegionConfig // Regions for text models, overriding Region (optional)
+	RegionStrategy string         // RegionStrategyOrdered (default) or RegionStrategyWeighted
+	RegionCooldown time.Duration  // How long a failing region is skipped (default: 30s)
+
+	RateLimit        *RateLimit     // Default client-side limits applied to each text model (optional)
+	ContextWindow    *ContextWindow // Default history truncation applied to each text model (optional)
+	MaxContinuations int            // Times text output cut off at max tokens is continued (default: 0, disabled)
+
+	CacheTools  bool          // Add a cache point after the tool definitions, for models with prompt caching (default: false)
+	CacheTTL    time.Duration // TTL of cache points, DefaultCacheTTL or ExtendedCacheTTL (default: DefaultCacheTTL)
+	CachePolicy *CachePolicy  // Default automatic cache point placement for each text model (optional)
+
+	TracerProvider trace.TracerProvider // OpenTelemetry tracer provider (default: global provider)
+	MeterProvider  metric.MeterProvider // OpenTelemetry meter provider (default: global provider)
+	CaptureContent bool                 // Record prompts and completions on spans (default: false)
+	Logger         *slog.Logger         // Logger for calls, retries and dropped content (default: slog.Default())
+
+	CatalogFile      string                       // JSON model catalog merged over the built-in one (optional)
+	CatalogOverrides map[string]ModelCapabilities // Per-model capability overrides (optional)
+
+	mu            sync.Mutex         // Mutex to control access
+	controlClient *awsbedrock.Client // Bedrock control plane client
+	stsClient     *sts.Client        // STS client used to assume tenant roles
+	tenants       *tenantCache       // Assumed role credentials per tenant
+	noCountTokens map[string]bool    // Models for which CountTokens is unsupported
+	initted       bool               // Whether the plugin has been initialized
+	catalog       *ModelCatalog      // Model capabilities
+	regions       *regionPool        // Runtime clients used by text models
+	telemetry     *telemetry         // Tracing and metrics
+
+	listMu   sync.Mutex    // Guards the cached model listing
+	listed   []listedModel // Cached model listing
+	listedAt time.Time     // When the listing was fetched
+
+	limitMu        sync.Mutex                // Guards limiters, contextWindows, continuations and cachePolicies
+	limiters       map[string]*rateLimiter   // Rate limiters by model ID
+	contextWindows map[string]*ContextWindow // Context window settings by model ID
+	continuations  map[string]int            // Maximum continuations by model ID
+	cachePolicies  map[string]*CachePolicy   // Cache policies by model ID
+
+	profileMu    sync.Mutex                  // Guards baseModelIDs
+	baseModelIDs map[string]*baseModelLookup // Base model lookups of application profiles and provisioned models
 }
 
 // ModelDefinition represents a model with its name and type.
 type ModelDefinition struct {
 	Name string // Model ID as used in AWS Bedrock
 	Type string // Type: "chat", "text", "image", "embedding"
+
+	RateLimit        *RateLimit     // Client-side limits for this model, overriding Bedrock.RateLimit (optional)
+	ContextWindow    *ContextWindow // History truncation for this model, overriding Bedrock.ContextWindow (optional)
+	MaxContinuations int            // Continuations for this model, overriding Bedrock.MaxContinuations; -1 disables them (optional)
+	CachePolicy      *CachePolicy   // Cache point placement for this model, overriding Bedrock.CachePolicy (optional)
 }
 
-// Name returns the provider name.
+// Name returns the provider name, which is the namespace models and embedders are registered under.
 func (b *Bedrock) Name() string {
+	if b.Namespace != "" {
+		return b.Namespace
+	}
 	return provider
 }
 
@@ -203,6 +191,31 @@ func (b *Bedrock) Init(ctx context.Context) []api.Action {
 	if b.RequestTimeout == 0 {
 		b.RequestTimeout = 30 * time.Second
 	}
+	if b.ModelListTTL == 0 {
+		b.ModelListTTL = time.Hour
+	}
+	if b.TenantCacheTTL == 0 {
+		b.TenantCacheTTL = time.Hour
+	}
+	if b.RegionStrategy == "" {
+		b.RegionStrategy = RegionStrategyOrdered
+	}
+	if b.RegionCooldown == 0 {
+		b.RegionCooldown = 30 * time.Second
+	}
+
+	if _, err := cacheTTL(b.CacheTTL); err != nil {
+		b.mu.Unlock()
+		panic(fmt.Sprintf("bedrock: %v", err))
+	}
+
+	b.telemetry = newTelemetry(b.TracerProvider, b.MeterProvider, b.CaptureContent, b.logger())
+
+	// Load the model capability catalog
+	if err := b.loadCatalog(); err != nil {
+		b.mu.Unlock()
+		panic(fmt.Sprintf("bedrock: failed to load model catalog: %v", err))
+	}
 
 	// Load AWS configuration
 	var awsConfig aws.Config
@@ -221,8 +234,50 @@ func (b *Bedrock) Init(ctx context.Context) []api.Action {
 		}
 	}
 
-	// Create Bedrock Runtime client
-	b.client = bedrockruntime.NewFromConfig(awsConfig)
+	// Apply endpoint, HTTP and credential settings
+	if err := b.applyClientConfig(&awsConfig); err != nil {
+		b.mu.Unlock()
+		panic(fmt.Sprintf("bedrock: %v", err))
+	}
+
+	// Create Bedrock Runtime clients, one per region when multiple regions are configured.
+	// The first region also serves image and embedding models.
+	b.regions = &regionPool{strategy: b.RegionStrategy, cooldown: b.RegionCooldown}
+	if len(b.Regions) == 0 {
+		client := b.Client
+		if client == nil {
+			client = NewBedrockClient(bedrockruntime.NewFromConfig(awsConfig, b.runtimeOptions(b.BaseEndpoint)...))
+		}
+		b.regions.regions = []*regionClient{{region: awsConfig.Region, weight: 1, client: client}}
+	}
+	for _, rc := range b.Regions {
+		client := rc.Client
+		if client == nil {
+			regionConfig := awsConfig.Copy()
+			regionConfig.Region = rc.Region
+			baseEndpoint := rc.BaseEndpoint
+			if baseEndpoint == "" {
+				baseEndpoint = b.BaseEndpoint
+			}
+			client = NewBedrockClient(bedrockruntime.NewFromConfig(regionConfig, b.runtimeOptions(baseEndpoint)...))
+		}
+		weight := rc.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		b.regions.regions = append(b.regions.regions, &regionClient{
+			region: rc.Region,
+			weight: weight,
+			client: client,
+		})
+	}
+
+	// Create Bedrock control plane client, used to list models for dynamic resolution
+	b.controlClient = awsbedrock.NewFromConfig(awsConfig, b.controlOptions()...)
+
+	// Create STS client, used to assume tenant roles with the plugin's credentials
+	b.stsClient = sts.NewFromConfig(awsConfig)
+	b.tenants = &tenantCache{ttl: b.TenantCacheTTL}
 
 	b.initted = true
 
@@ -230,7 +285,15 @@ func (b *Bedrock) Init(ctx context.Context) []api.Action {
 	b.mu.Unlock()
 
 	// Don't defer unlock since we already unlocked manually
-	return []api.Action{}
+	return []api.Action{b.countTokensAction()}
+}
+
+// logger returns the configured logger or the default one.
+func (b *Bedrock) logger() *slog.Logger {
+	if b.Logger != nil {
+		return b.Logger
+	}
+	return slog.Default()
 }
 
 // DefineModel defines a model in the registry.
@@ -243,70 +306,109 @@ func (b *Bedrock) DefineModel(g *genkit.Genkit, model ModelDefinition, info *ai.
 		panic("bedrock: Init not called")
 	}
 
+	if model.RateLimit != nil {
+		b.setModelRateLimit(model.Name, model.RateLimit)
+	}
+	if model.ContextWindow != nil {
+		b.setModelContextWindow(model.Name, model.ContextWindow)
+	}
+	if model.MaxContinuations != 0 {
+		b.setModelMaxContinuations(model.Name, model.MaxContinuations)
+	}
+	if model.CachePolicy != nil {
+		b.setModelCachePolicy(model.Name, model.CachePolicy)
+	}
+
+	return genkit.DefineModel(g, api.NewName(b.Name(), model.Name), b.modelOptions(model, info), b.modelFunc(model))
+}
+
+// DefineEmbedder defines an embedder in the registry.
+func (b *Bedrock) DefineEmbedder(g *genkit.Genkit, modelName string) ai.Embedder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.initted {
+		panic("bedrock: Init not called")
+	}
+
+	return genkit.DefineEmbedder(g, api.NewName(b.Name(), modelName), nil, b.embedderFunc(modelName))
+}
+
+// modelOptions builds the model metadata, auto-detecting capabilities if info is nil.
+func (b *Bedrock) modelOptions(model ModelDefinition, info *ai.ModelInfo) *ai.ModelOptions {
 	// Auto-detect model capabilities if not provided
 	if info == nil {
 		info = b.inferModelCapabilities(model.Name, model.Type)
 	}
 
-	// Create model metadata
-	meta := &ai.ModelOptions{
-		Label:    provider + "-" + model.Name,
+	return &ai.ModelOptions{
+		Label:    b.Name() + "-" + model.Name,
 		Supports: info.Supports,
 		Versions: info.Versions,
 	}
+}
 
-	// Create the model function based on model type
+// modelFunc returns the generation function for the model based on its type.
+func (b *Bedrock) modelFunc(model ModelDefinition) ai.ModelFunc {
 	switch model.Type {
 	case "image":
-		return genkit.DefineModel(g, api.NewName(provider, model.Name), meta, func(
+		return func(
 			ctx context.Context,
 			input *ai.ModelRequest,
 			cb func(context.Context, *ai.ModelResponseChunk) error,
 		) (*ai.ModelResponse, error) {
 			return b.generateImage(ctx, model.Name, input, cb)
-		})
+		}
 	default:
-		return genkit.DefineModel(g, api.NewName(provider, model.Name), meta, func(
+		return func(
 			ctx context.Context,
 			input *ai.ModelRequest,
 			cb func(context.Context, *ai.ModelResponseChunk) error,
 		) (*ai.ModelResponse, error) {
 			return b.generateText(ctx, model.Name, input, cb)
-		})
+		}
 	}
 }
 
-// DefineEmbedder defines an embedder in the registry.
-func (b *Bedrock) DefineEmbedder(g *genkit.Genkit, modelName string) ai.Embedder {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	if !b.initted {
-		panic("bedrock: Init not called")
-	}
-
-	return genkit.DefineEmbedder(g, api.NewName(provider, modelName), nil, func(
+// embedderFunc returns the embedding function for the model.
+func (b *Bedrock) embedderFunc(modelName string) ai.EmbedderFunc {
+	return func(
 		ctx context.Context,
 		req *ai.EmbedRequest,
 	) (*ai.EmbedResponse, error) {
 		return b.embed(ctx, modelName, req)
-	})
+	}
 }
 
-// IsDefinedModel reports whether a model is defined.
+// IsDefinedModel reports whether a model is defined in the default "bedrock" namespace.
 func IsDefinedModel(g *genkit.Genkit, name string) bool {
 	return genkit.LookupModel(g, api.NewName(provider, name)) != nil
 }
 
-// Model returns the Model with the given name.
+// Model returns the Model with the given name in the default "bedrock" namespace.
 func Model(g *genkit.Genkit, name string) ai.Model {
 	return genkit.LookupModel(g, api.NewName(provider, name))
 }
 
-// inferModelCapabilities infers model capabilities based on model name and type.
+// IsDefinedModel reports whether a model is defined in the plugin namespace.
+func (b *Bedrock) IsDefinedModel(g *genkit.Genkit, name string) bool {
+	return genkit.LookupModel(g, api.NewName(b.Name(), name)) != nil
+}
+
+// Model returns the Model with the given name in the plugin namespace.
+func (b *Bedrock) Model(g *genkit.Genkit, name string) ai.Model {
+	return genkit.LookupModel(g, api.NewName(b.Name(), name))
+}
+
+// Embedder returns the Embedder with the given name in the plugin namespace.
+func (b *Bedrock) Embedder(g *genkit.Genkit, name string) ai.Embedder {
+	return genkit.LookupEmbedder(g, api.NewName(b.Name(), name))
+}
+
+// inferModelCapabilities infers model capabilities from the model catalog based on model name and type.
+// Inference profile IDs and ARNs are looked up by the foundation model they route to.
 func (b *Bedrock) inferModelCapabilities(modelName, modelType string) *ai.ModelInfo {
-	supportsTools := slices.Contains(toolSupportedModels, modelName)
-	supportsMedia := slices.Contains(multimodalModels, modelName)
+	caps, known := b.capabilities(context.Background(), modelName)
 
 	switch modelType {
 	case "image":
@@ -334,9 +436,9 @@ func (b *Bedrock) inferModelCapabilities(modelName, modelType string) *ai.ModelI
 			Label: modelName,
 			Supports: &ai.ModelSupports{
 				Multiturn:  true,
-				Tools:      supportsTools,
-				SystemRole: true,
-				Media:      supportsMedia,
+				Tools:      caps.Tools,
+				SystemRole: !known || caps.SystemPrompt,
+				Media:      caps.SupportsInput(ModalityImage) || caps.Video || caps.Documents, // Checked by content type in validateRequest
 			},
 		}
 	}
@@ -344,12 +446,146 @@ func (b *Bedrock) inferModelCapabilities(modelName, modelType string) *ai.ModelI
 
 // generateText handles text generation using Bedrock Converse API
 func (b *Bedrock) generateText(ctx context.Context, modelName string, input *ai.ModelRequest, cb func(context.Context, *ai.ModelResponseChunk) error) (*ai.ModelResponse, error) {
+	ctx, err := b.withRequestTenant(ctx, modelName, input)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reject requests the model cannot serve before calling Bedrock
+	caps, known := b.capabilities(ctx, modelName)
+	if known {
+		if err := validateRequest(modelName, caps, input, cb != nil); err != nil {
+			return nil, err
+		}
+	}
+
+	input, prepared, err := b.prepareRequest(ctx, modelName, caps, known, input)
+	if err != nil {
+		return nil, err
+	}
+
 	// Convert Genkit request to Bedrock Converse input
-	converseInput, err := b.buildConverseInput(modelName, input)
+	converseInput, err := b.buildConverseInput(ctx, modelName, input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build converse input: %w", err)
 	}
 
+	resp, err := b.converseWithContinuation(ctx, modelName, converseInput, input, cb)
+	if resp != nil && prepared.contextWindow != nil {
+		setResponseMetadata(resp, "contextWindow", prepared.contextWindow)
+	}
+	if resp != nil && prepared.droppedPrefill {
+		setResponseMetadata(resp, "droppedPrefill", true)
+	}
+	return resp, err
+}
+
+// preparedRequest reports how prepareRequest changed a request.
+type preparedRequest struct {
+	droppedPrefill bool                 // A trailing model message was dropped
+	contextWindow  *ContextWindowReport // Turns dropped to fit the context window, if any
+}
+
+// prepareRequest applies the changes made to every request before it is converted to a
+// Converse input: dropping an unsupported assistant prefill, fitting the context window and
+// placing the cache points of the model's cache policy.
+func (b *Bedrock) prepareRequest(ctx context.Context, modelName string, caps ModelCapabilities, known bool, input *ai.ModelRequest) (*ai.ModelRequest, preparedRequest, error) {
+	var prepared preparedRequest
+
+	// Drop an assistant prefill the model rejects, reporting it in the response
+	input, prepared.droppedPrefill = b.dropUnsupportedPrefill(ctx, modelName, caps, known, input)
+
+	// Drop older turns that don't fit the context window
+	input, report, err := b.fitContextWindow(ctx, modelName, input)
+	if err != nil {
+		return nil, prepared, err
+	}
+	prepared.contextWindow = report
+
+	// Place the cache points of the model's cache policy
+	return b.placeCachePoints(ctx, modelName, caps, known, input), prepared, nil
+}
+
+// dropUnsupportedPrefill drops a trailing text-only model message when the model doesn't
+// support assistant prefill, reporting whether it did.
+func (b *Bedrock) dropUnsupportedPrefill(ctx context.Context, modelName string, caps ModelCapabilities, known bool, input *ai.ModelRequest) (*ai.ModelRequest, bool) {
+	if !known || caps.Prefill || !endsWithPrefill(input) {
+		return input, false
+	}
+	b.logger().WarnContext(ctx, "bedrock: dropping trailing model message, the model does not support assistant prefill", "model", modelName)
+	trimmed := *input
+	trimmed.Messages = input.Messages[:len(input.Messages)-1]
+	return &trimmed, true
+}
+
+// limitedConverse calls converse once client-side rate limits allow it, reconciling the token
+// estimate with the actual usage.
+func (b *Bedrock) limitedConverse(ctx context.Context, modelName string, converseInput *bedrockruntime.ConverseInput, input *ai.ModelRequest, cb func(context.Context, *ai.ModelResponseChunk) error) (*ai.ModelResponse, error) {
+	limiter := b.limiterFor(modelName)
+	if limiter == nil {
+		return b.converse(ctx, converseInput, input, cb)
+	}
+
+	release, err := limiter.acquire(ctx, modelName, estimateTokens(input))
+	if err != nil {
+		return nil, err
+	}
+	usedTokens := -1
+	defer func() { release(usedTokens) }()
+
+	resp, err := b.converse(ctx, converseInput, input, cb)
+	if resp != nil && resp.Usage != nil {
+		usedTokens = resp.Usage.TotalTokens
+	}
+	return resp, err
+}
+
+// endsWithPrefill reports whether the conversation ends with a model message made up of text
+// only, which Bedrock treats as a prefill. A trailing model message with tool requests is not a
+// prefill, its tool calls are answered with placeholder results when the history is normalized.
+func endsWithPrefill(input *ai.ModelRequest) bool {
+	n := len(input.Messages)
+	if n == 0 || input.Messages[n-1].Role != ai.RoleModel || len(input.Messages[n-1].Content) == 0 {
+		return false
+	}
+	for _, part := range input.Messages[n-1].Content {
+		if !part.IsText() {
+			return false
+		}
+	}
+	return true
+}
+
+// trimPrefill trims trailing whitespace from a trailing assistant message, the prefill the model
+// continues from. Bedrock rejects prefills ending with whitespace or empty text blocks, so text
+// blocks left empty are dropped, and so is the message when nothing remains.
+func trimPrefill(messages []types.Message) []types.Message {
+	n := len(messages)
+	if n == 0 || messages[n-1].Role != types.ConversationRoleAssistant {
+		return messages
+	}
+	content := messages[n-1].Content
+	for len(content) > 0 {
+		text, ok := content[len(content)-1].(*types.ContentBlockMemberText)
+		if !ok {
+			break
+		}
+		trimmed := strings.TrimRightFunc(text.Value, unicode.IsSpace)
+		if trimmed != "" {
+			content[len(content)-1] = &types.ContentBlockMemberText{Value: trimmed}
+			break
+		}
+		content = content[:len(content)-1]
+	}
+	if len(content) == 0 {
+		return messages[:n-1]
+	}
+	messages[n-1].Content = content
+	return messages
+}
+
+// converse calls the Converse or ConverseStream API depending on whether a callback is set.
+func (b *Bedrock) converse(ctx context.Context, converseInput *bedrockruntime.ConverseInput, input *ai.ModelRequest, cb func(context.Context, *ai.ModelResponseChunk) error) (*ai.ModelResponse, error) {
 	// Handle streaming vs non-streaming
 	if cb != nil {
 		return b.generateTextStream(ctx, converseInput, input, cb)
@@ -357,6 +593,28 @@ func (b *Bedrock) generateText(ctx context.Context, modelName string, input *ai.
 	return b.generateTextSync(ctx, converseInput, input)
 }
 
+// invokeModel calls the InvokeModel API with tracing, classifying errors.
+func (b *Bedrock) invokeModel(ctx context.Context, operation string, input *bedrockruntime.InvokeModelInput) (*bedrockruntime.InvokeModelOutput, error) {
+	modelID := aws.ToString(input.ModelId)
+	// Image and embedding models are served by the first region
+	rc := b.regions.regions[0]
+	optFns, err := b.runtimeCallOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, call := b.telemetry.startCall(ctx, operation, modelID, rc.region)
+
+	response, err := rc.client.InvokeModel(ctx, input, optFns...)
+	if err != nil {
+		err = newError("InvokeModel", modelID, err)
+		call.end(ctx, callResult{Err: err})
+		return nil, err
+	}
+
+	call.end(ctx, callResult{Metadata: response.ResultMetadata})
+	return response, nil
+}
+
 // generateImage handles image generation using Bedrock InvokeModel API
 func (b *Bedrock) generateImage(ctx context.Context, modelName string, input *ai.ModelRequest, cb func(context.Context, *ai.ModelResponseChunk) error) (*ai.ModelResponse, error) {
 	// Extract prompt from the first message
@@ -372,12 +630,13 @@ func (b *Bedrock) generateImage(ctx context.Context, modelName string, input *ai
 	}
 
 	// Generate image based on model type
+	baseModel := b.resolveBaseModelID(ctx, modelName)
 	switch {
-	case strings.Contains(modelName, "titan-image"):
+	case strings.Contains(baseModel, "titan-image"):
 		return b.generateTitanImage(ctx, modelName, prompt, input.Config, cb)
-	case strings.Contains(modelName, "stable-diffusion"), strings.Contains(modelName, "sd3-"), strings.Contains(modelName, "stable-image"):
+	case strings.Contains(baseModel, "stable-diffusion"), strings.Contains(baseModel, "sd3-"), strings.Contains(baseModel, "stable-image"):
 		return b.generateStableDiffusionImage(ctx, modelName, prompt, input.Config, cb)
-	case strings.Contains(modelName, "nova-canvas"):
+	case strings.Contains(baseModel, "nova-canvas"):
 		return b.generateNovaCanvasImage(ctx, modelName, prompt, input.Config, cb)
 	default:
 		return nil, fmt.Errorf("unsupported image generation model: %s", modelName)
@@ -428,9 +687,9 @@ func (b *Bedrock) generateTitanImage(ctx context.Context, modelName, prompt stri
 		Accept:      aws.String("application/json"),
 	}
 
-	response, err := b.client.InvokeModel(ctx, input)
+	response, err := b.invokeModel(ctx, genAIOperationGenerate, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to invoke model: %w", err)
+		return nil, err
 	}
 
 	// Parse response
@@ -499,9 +758,9 @@ func (b *Bedrock) generateStableDiffusionImage(ctx context.Context, modelName, p
 		Accept:      aws.String("application/json"),
 	}
 
-	response, err := b.client.InvokeModel(ctx, input)
+	response, err := b.invokeModel(ctx, genAIOperationGenerate, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to invoke model: %w", err)
+		return nil, err
 	}
 
 	// Parse response
@@ -555,10 +814,10 @@ func (b *Bedrock) embed(ctx context.Context, modelName string, req *ai.EmbedRequ
 		var embedding []float32
 		var err error
 
-		switch {
-		case strings.Contains(modelName, "titan"):
+		switch baseModel := b.resolveBaseModelID(ctx, modelName); {
+		case strings.Contains(baseModel, "titan"):
 			embedding, err = b.getTitanEmbedding(ctx, modelName, inputText)
-		case strings.Contains(modelName, "cohere"):
+		case strings.Contains(baseModel, "cohere"):
 			embedding, err = b.getCohereEmbedding(ctx, modelName, inputText)
 		default:
 			return nil, fmt.Errorf("unsupported embedding model: %s", modelName)
@@ -599,9 +858,9 @@ func (b *Bedrock) getTitanEmbedding(ctx context.Context, modelName, text string)
 		Accept:      aws.String("application/json"),
 	}
 
-	response, err := b.client.InvokeModel(ctx, input)
+	response, err := b.invokeModel(ctx, genAIOperationEmbeddings, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to invoke model: %w", err)
+		return nil, err
 	}
 
 	// Parse response
@@ -638,9 +897,9 @@ func (b *Bedrock) getCohereEmbedding(ctx context.Context, modelName, text string
 		Accept:      aws.String("application/json"),
 	}
 
-	response, err := b.client.InvokeModel(ctx, input)
+	response, err := b.invokeModel(ctx, genAIOperationEmbeddings, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to invoke model: %w", err)
+		return nil, err
 	}
 
 	// Parse response
@@ -704,9 +963,9 @@ func (b *Bedrock) generateNovaCanvasImage(ctx context.Context, modelName, prompt
 		Accept:      aws.String("application/json"),
 	}
 
-	response, err := b.client.InvokeModel(ctx, input)
+	response, err := b.invokeModel(ctx, genAIOperationGenerate, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to invoke model: %w", err)
+		return nil, err
 	}
 
 	// Parse response (Nova Canvas uses similar format to Titan)
@@ -735,15 +994,20 @@ func (b *Bedrock) generateNovaCanvasImage(ctx context.Context, modelName, prompt
 }
 
 // buildConverseInput converts Genkit ModelRequest to Bedrock ConverseInput
-func (b *Bedrock) buildConverseInput(modelName string, input *ai.ModelRequest) (*bedrockruntime.ConverseInput, error) {
+func (b *Bedrock) buildConverseInput(ctx context.Context, modelName string, input *ai.ModelRequest) (*bedrockruntime.ConverseInput, error) {
 	converseInput := &bedrockruntime.ConverseInput{
 		ModelId: aws.String(modelName),
 	}
 
+	// Tool results and cache points use the features the model supports, the most basic ones
+	// for unknown models
+	caps, known := b.capabilities(ctx, modelName)
+
 	// Convert messages
 	if len(input.Messages) > 0 {
 		var messages []types.Message
 		var systemPrompts []types.SystemContentBlock
+		documents := 0 // Documents attached to the conversation, for unique names
 
 		for _, msg := range input.Messages {
 			switch msg.Role {
@@ -756,13 +1020,19 @@ func (b *Bedrock) buildConverseInput(modelName string, input *ai.ModelRequest) (
 						})
 					} else if part.IsCustom() {
 						// Handle custom parts, the plugin currently supports NewCachePointPart
-						if cpt, ok := CachePointType(part); ok {
+						cachePoint, ok, err := b.cachePointBlock(ctx, modelName, caps, known, part)
+						if err != nil {
+							return nil, err
+						}
+						if ok {
 							systemPrompts = append(systemPrompts, &types.SystemContentBlockMemberCachePoint{
-								Value: types.CachePointBlock{
-									Type: cpt,
-								},
+								Value: cachePoint,
 							})
+						} else {
+							b.logger().WarnContext(ctx, "bedrock: ignoring unsupported custom part in system message", "model", modelName)
 						}
+					} else {
+						b.logger().WarnContext(ctx, "bedrock: ignoring unsupported part in system message", "model", modelName, "kind", part.Kind)
 					}
 				}
 			case ai.RoleUser, ai.RoleModel, ai.RoleTool:
@@ -775,63 +1045,12 @@ func (b *Bedrock) buildConverseInput(modelName string, input *ai.ModelRequest) (
 						})
 					} else if part.IsMedia() {
 						// Handle media parts for multimodal models
-						mediaType := part.ContentType
-						var imageBlock *types.ContentBlockMemberImage
-
-						// Parse data URL or direct content
-						content := part.Text
-						if strings.HasPrefix(content, "data:") {
-							// Handle data URL format: data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAA...
-							parts := strings.Split(content, ",")
-							if len(parts) == 2 {
-								// Extract the actual base64 data
-								content = parts[1]
-								// Extract MIME type from data URL if not already set
-								if mediaType == "" {
-									urlParts := strings.Split(parts[0], ":")
-									if len(urlParts) > 1 {
-										mimeAndEncoding := strings.Split(urlParts[1], ";")
-										if len(mimeAndEncoding) > 0 {
-											mediaType = mimeAndEncoding[0]
-										}
-									}
-								}
-							}
-						}
-
-						// Convert to appropriate image format based on MIME type
-						var format types.ImageFormat
-						switch mediaType {
-						case "image/png":
-							format = types.ImageFormatPng
-						case "image/jpeg", "image/jpg":
-							format = types.ImageFormatJpeg
-						case "image/gif":
-							format = types.ImageFormatGif
-						case "image/webp":
-							format = types.ImageFormatWebp
-						default:
-							// Default to PNG if unknown
-							format = types.ImageFormatPng
-						}
-
-						// Decode base64 content
-						imageData, err := base64.StdEncoding.DecodeString(content)
+						mediaType, data := mediaPartData(part)
+						block, err := mediaBlock(mediaType, data, &documents)
 						if err != nil {
-							// If decoding fails, try using the content directly
-							imageData = []byte(content)
-						}
-
-						imageBlock = &types.ContentBlockMemberImage{
-							Value: types.ImageBlock{
-								Format: format,
-								Source: &types.ImageSourceMemberBytes{
-									Value: imageData,
-								},
-							},
+							return nil, newValidationError(modelName, err.Error())
 						}
-
-						contentBlocks = append(contentBlocks, imageBlock)
+						contentBlocks = append(contentBlocks, block)
 					} else if part.IsToolRequest() {
 						// Handle tool request parts - convert to Bedrock ToolUse blocks
 						toolReq := part.ToolRequest
@@ -850,50 +1069,24 @@ func (b *Bedrock) buildConverseInput(modelName string, input *ai.ModelRequest) (
 						}
 					} else if part.IsToolResponse() {
 						// Handle tool response parts - convert to Bedrock ToolResult blocks
-						toolResp := part.ToolResponse
-						if toolResp != nil {
-							// Create content for tool result
-							var toolResultContent []types.ToolResultContentBlock
-
-							// Convert the output to text content
-							if toolResp.Output != nil {
-								outputText := ""
-								switch output := toolResp.Output.(type) {
-								case string:
-									outputText = output
-								default:
-									// Marshal to JSON if not a string
-									if jsonBytes, err := json.Marshal(output); err == nil {
-										outputText = string(jsonBytes)
-									} else {
-										outputText = fmt.Sprintf("%v", output)
-									}
-								}
-
-								toolResultContent = append(toolResultContent, &types.ToolResultContentBlockMemberText{
-									Value: outputText,
-								})
-							}
-
-							toolResultBlock := &types.ContentBlockMemberToolResult{
-								Value: types.ToolResultBlock{
-									ToolUseId: aws.String(toolResp.Ref),
-									Content:   toolResultContent,
-									Status:    types.ToolResultStatusSuccess,
-								},
-							}
-
-							contentBlocks = append(contentBlocks, toolResultBlock)
+						if part.ToolResponse != nil {
+							contentBlocks = append(contentBlocks, b.toolResultBlock(ctx, modelName, caps, part))
 						}
 					} else if part.IsCustom() {
 						// Handle custom parts, the plugin currently supports NewCachePointPart
-						if cpt, ok := CachePointType(part); ok {
+						cachePoint, ok, err := b.cachePointBlock(ctx, modelName, caps, known, part)
+						if err != nil {
+							return nil, err
+						}
+						if ok {
 							contentBlocks = append(contentBlocks, &types.ContentBlockMemberCachePoint{
-								Value: types.CachePointBlock{
-									Type: cpt,
-								},
+								Value: cachePoint,
 							})
+						} else {
+							b.logger().WarnContext(ctx, "bedrock: ignoring unsupported custom part", "model", modelName, "role", msg.Role)
 						}
+					} else {
+						b.logger().WarnContext(ctx, "bedrock: ignoring unsupported part", "model", modelName, "role", msg.Role, "kind", part.Kind)
 					}
 				}
 
@@ -911,18 +1104,20 @@ func (b *Bedrock) buildConverseInput(modelName string, input *ai.ModelRequest) (
 			}
 		}
 
-		converseInput.Messages = messages
-
-		// When using tools, AWS Bedrock requires that the conversation doesn't end with an assistant message
-		if len(input.Tools) > 0 && len(messages) > 0 {
-			lastMessage := messages[len(messages)-1]
-			if lastMessage.Role == types.ConversationRoleAssistant {
-				// Remove the last assistant message or convert it to user context
-				// For now, we'll just remove it to avoid the validation error
-				messages = messages[:len(messages)-1]
-				converseInput.Messages = messages
-			}
+		// Repair histories that break the Converse rules, e.g. consecutive messages with the same
+		// role or tool results separated from their tool calls
+		normalized, err := normalizeMessages(messages)
+		if err != nil {
+			return nil, newValidationError(modelName, err.Error())
+		}
+		if len(normalized) != len(messages) {
+			b.logger().DebugContext(ctx, "bedrock: normalized conversation", "model", modelName, "messages", len(messages), "normalized", len(normalized))
 		}
+		messages = normalized
+		if !caps.ToolResultStatus {
+			clearToolResultStatus(messages)
+		}
+		converseInput.Messages = trimPrefill(messages)
 
 		if len(systemPrompts) > 0 {
 			converseInput.System = systemPrompts
@@ -979,6 +1174,19 @@ func (b *Bedrock) buildConverseInput(modelName string, input *ai.ModelRequest) (
 			tools = append(tools, toolSpec)
 		}
 
+		// Cache the tool definitions, which often are the largest static part of the prompt
+		if b.cacheToolsFor(input) {
+			if known && !caps.PromptCaching {
+				b.logger().DebugContext(ctx, "bedrock: not caching tools, the model does not support prompt caching", "model", modelName)
+			} else {
+				cachePoint, err := b.cachePoint(ctx, modelName, caps, known, types.CachePointTypeDefault, 0)
+				if err != nil {
+					return nil, err
+				}
+				tools = append(tools, &types.ToolMemberCachePoint{Value: cachePoint})
+			}
+		}
+
 		converseInput.ToolConfig = &types.ToolConfiguration{
 			Tools: tools,
 		}
@@ -989,18 +1197,49 @@ func (b *Bedrock) buildConverseInput(modelName string, input *ai.ModelRequest) (
 
 // generateTextSync handles synchronous text generation
 func (b *Bedrock) generateTextSync(ctx context.Context, input *bedrockruntime.ConverseInput, originalInput *ai.ModelRequest) (*ai.ModelResponse, error) {
-	// Call Bedrock Converse API
-	response, err := b.client.Converse(ctx, input)
+	optFns, err := b.runtimeCallOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Call Bedrock Converse API, failing over to other regions on throttling or outages
+	response, region, err := withRegionFailover(ctx, b.regions, b.logger(), func(rc *regionClient) (*bedrockruntime.ConverseOutput, error) {
+		ctx, call := b.telemetry.startCall(ctx, genAIOperationChat, aws.ToString(input.ModelId), rc.region)
+		call.setRequest(input.InferenceConfig, input.System, input.Messages)
+
+		out, err := rc.client.Converse(ctx, input, optFns...)
+		if err != nil {
+			err = newError("Converse", aws.ToString(input.ModelId), err)
+			call.end(ctx, callResult{Err: err})
+			return nil, err
+		}
+
+		call.end(ctx, callResult{
+			StopReason: out.StopReason,
+			Usage:      out.Usage,
+			Output:     outputText(out.Output),
+			Metadata:   out.ResultMetadata,
+		})
+		return out, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("bedrock converse failed: %w", err)
+		return nil, err
+	}
+
+	// A guardrail intervention is reported as an error so callers can handle it explicitly
+	if response.StopReason == types.StopReasonGuardrailIntervened {
+		return nil, newGuardrailError("Converse", aws.ToString(input.ModelId), outputText(response.Output))
 	}
 
 	// Convert response to Genkit format
-	return b.convertResponse(response, originalInput), nil
+	modelResponse := b.convertResponse(response, originalInput)
+	setResponseMetadata(modelResponse, "region", region.region)
+	setResponseMetadata(modelResponse, "stopReason", string(response.StopReason))
+	return modelResponse, nil
 }
 
 // generateTextStream handles streaming text generation
-func (b *Bedrock) generateTextStream(ctx context.Context, input *bedrockruntime.ConverseInput, originalInput *ai.ModelRequest, cb func(context.Context, *ai.ModelResponseChunk) error) (*ai.ModelResponse, error) {
+func (b *Bedrock) generateTextStream(ctx context.Context, input *bedrockruntime.ConverseInput, originalInput *ai.ModelRequest, cb func(context.Context, *ai.ModelResponseChunk) error) (_ *ai.ModelResponse, retErr error) {
 	// Convert ConverseInput to ConverseStreamInput
 	streamInput := &bedrockruntime.ConverseStreamInput{
 		ModelId:                      input.ModelId,
@@ -1011,35 +1250,129 @@ func (b *Bedrock) generateTextStream(ctx context.Context, input *bedrockruntime.
 		AdditionalModelRequestFields: input.AdditionalModelRequestFields,
 	}
 
-	// Call Bedrock ConverseStream API
-	streamOutput, err := b.client.ConverseStream(ctx, streamInput)
+	optFns, err := b.runtimeCallOptions(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("bedrock converse stream failed: %w", err)
+		return nil, err
 	}
-	defer func() {
-		if closeErr := streamOutput.GetStream().Close(); closeErr != nil {
-			// Log the error but don't fail the operation
-			// In a real implementation, you might want to use a proper logger
-			_ = closeErr
+
+	// Call Bedrock ConverseStream API, failing over to other regions while setting up the stream
+	// The span of a successful call stays open until the stream has been consumed
+	var call *callSpan
+	stream, region, err := withRegionFailover(ctx, b.regions, b.logger(), func(rc *regionClient) (*ConverseStream, error) {
+		var callCtx context.Context
+		callCtx, call = b.telemetry.startCall(ctx, genAIOperationChat, aws.ToString(input.ModelId), rc.region)
+		call.setRequest(input.InferenceConfig, input.System, input.Messages)
+
+		out, err := rc.client.ConverseStream(callCtx, streamInput, optFns...)
+		if err != nil {
+			err = newError("ConverseStream", aws.ToString(input.ModelId), err)
+			call.end(callCtx, callResult{Err: err})
+			return nil, err
 		}
-	}()
+		return out, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	modelID := aws.ToString(input.ModelId)
 
 	// Build final response
 	var fullText strings.Builder
+	var toolRequests []*ai.Part
+	toolUses := map[int32]*streamedToolUse{} // Tool calls being streamed, by content block index
 	var finalResponse *ai.ModelResponse
 	var stopReason types.StopReason
+	var usage *types.TokenUsage
+
+	defer func() {
+		call.end(ctx, callResult{
+			StopReason: stopReason,
+			Usage:      usage,
+			Output:     fullText.String(),
+			Metadata:   stream.ResultMetadata,
+			Err:        retErr,
+		})
+	}()
+
+	// Closing the stream stops the reader goroutine, including when we return early
+	// because the callback failed or the context was cancelled
+	defer func() {
+		if closeErr := stream.Reader.Close(); closeErr != nil {
+			// Log the error but don't fail the operation
+			b.logger().WarnContext(ctx, "bedrock: failed to close stream", "model", aws.ToString(input.ModelId), "error", closeErr)
+		}
+	}()
+
+	// content returns the text and completed tool calls received so far
+	content := func() []*ai.Part {
+		var parts []*ai.Part
+		if fullText.Len() > 0 || len(toolRequests) == 0 {
+			parts = append(parts, ai.NewTextPart(fullText.String()))
+		}
+		return append(parts, toolRequests...)
+	}
+
+	// partial returns the content received so far, reported with mid-stream failures
+	partial := func() *ai.ModelResponse {
+		return &ai.ModelResponse{
+			Message: &ai.Message{
+				Role:    ai.RoleModel,
+				Content: content(),
+			},
+			FinishReason: ai.FinishReasonOther,
+		}
+	}
+
+	// streamError reports a mid-stream failure, putting the region in cooldown if it is at fault
+	streamError := func(err error) error {
+		e := newStreamError(modelID, err, partial())
+		e.Region = region.region
+		if isFailoverError(e) && ctx.Err() == nil {
+			b.regions.markFailure(region)
+		}
+		return e
+	}
+
+	// Process stream events until the stream ends or the context is cancelled
+	events := stream.Reader.Events()
+	for done := false; !done; {
+		var event types.ConverseStreamOutput
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return nil, streamError(ctx.Err())
+		case event, ok = <-events:
+			if !ok {
+				done = true
+				continue
+			}
+		}
 
-	// Process stream events
-	for event := range streamOutput.GetStream().Events() {
 		switch e := event.(type) {
 
+		case *types.ConverseStreamOutputMemberContentBlockStart:
+			// A tool call starts, its input follows as JSON deltas
+			if start, ok := e.Value.Start.(*types.ContentBlockStartMemberToolUse); ok {
+				toolUses[aws.ToInt32(e.Value.ContentBlockIndex)] = &streamedToolUse{
+					id:   aws.ToString(start.Value.ToolUseId),
+					name: aws.ToString(start.Value.Name),
+				}
+			}
+
 		case *types.ConverseStreamOutputMemberContentBlockDelta:
-			// Text delta received
 			deltaEvent := e.Value
+			if toolDelta, ok := deltaEvent.Delta.(*types.ContentBlockDeltaMemberToolUse); ok {
+				if toolUse := toolUses[aws.ToInt32(deltaEvent.ContentBlockIndex)]; toolUse != nil {
+					toolUse.input.WriteString(aws.ToString(toolDelta.Value.Input))
+				}
+			}
 			if deltaEvent.Delta != nil {
+				// Text delta received
 				if textDelta, ok := deltaEvent.Delta.(*types.ContentBlockDeltaMemberText); ok {
 					text := textDelta.Value
 					fullText.WriteString(text)
+					call.chunk(ctx)
 
 					// Send chunk to callback
 					chunk := &ai.ModelResponseChunk{
@@ -1054,40 +1387,94 @@ func (b *Bedrock) generateTextStream(ctx context.Context, input *bedrockruntime.
 				}
 			}
 
+		case *types.ConverseStreamOutputMemberContentBlockStop:
+			// A tool call is complete once its content block stops
+			index := aws.ToInt32(e.Value.ContentBlockIndex)
+			toolUse := toolUses[index]
+			if toolUse == nil {
+				continue
+			}
+			delete(toolUses, index)
+
+			part := ai.NewToolRequestPart(b.streamedToolRequest(toolUse, originalInput.Tools))
+			toolRequests = append(toolRequests, part)
+			call.chunk(ctx)
+			chunk := &ai.ModelResponseChunk{
+				Index:   0,
+				Content: []*ai.Part{part},
+			}
+			if err := cb(ctx, chunk); err != nil {
+				return nil, fmt.Errorf("callback error: %w", err)
+			}
+
 		case *types.ConverseStreamOutputMemberMessageStop:
 			// Message ended - prepare final response
 			stopEvent := e.Value
 			stopReason = stopEvent.StopReason
+			if stopReason == types.StopReasonGuardrailIntervened {
+				return nil, newGuardrailError("ConverseStream", modelID, fullText.String())
+			}
 
 			finalResponse = &ai.ModelResponse{
 				Message: &ai.Message{
-					Role: ai.RoleModel,
-					Content: []*ai.Part{
-						ai.NewTextPart(fullText.String()),
-					},
+					Role:    ai.RoleModel,
+					Content: content(),
 				},
 				FinishReason: convertStopReasonToGenkit(stopReason),
 			}
 
+		case *types.ConverseStreamOutputMemberMetadata:
+			// Token usage is reported after the message stop event
+			usage = e.Value.Usage
+
 		}
 	}
 
-	// Return final response
+	// Exceptions sent by Bedrock mid-stream surface as the stream error once the events channel closes
+	if err := stream.Reader.Err(); err != nil {
+		return nil, streamError(err)
+	}
+
+	// A stream that ends without a message stop event was cut short
 	if finalResponse == nil {
-		finalResponse = &ai.ModelResponse{
-			Message: &ai.Message{
-				Role: ai.RoleModel,
-				Content: []*ai.Part{
-					ai.NewTextPart(fullText.String()),
-				},
-			},
-			FinishReason: ai.FinishReasonStop,
-		}
+		return nil, streamError(errStreamTruncated)
 	}
 
+	finalResponse.Usage = convertUsage(usage)
+	setResponseMetadata(finalResponse, "region", region.region)
+	setResponseMetadata(finalResponse, "stopReason", string(stopReason))
 	return finalResponse, nil
 }
 
+// streamedToolUse accumulates a tool call received over a ConverseStream response.
+type streamedToolUse struct {
+	id    string
+	name  string
+	input strings.Builder // JSON input, streamed in fragments
+}
+
+// streamedToolRequest converts a completely streamed tool call to a Genkit tool request.
+func (b *Bedrock) streamedToolRequest(toolUse *streamedToolUse, tools []*ai.ToolDefinition) *ai.ToolRequest {
+	var toolInput interface{} = map[string]interface{}{}
+	if toolUse.input.Len() > 0 {
+		var inputMap map[string]interface{}
+		if err := json.Unmarshal([]byte(toolUse.input.String()), &inputMap); err == nil {
+			toolInput = b.convertToolInputTypes(inputMap, toolUse.name, tools)
+		} else {
+			toolInput = map[string]interface{}{
+				"_unmarshal_error": err.Error(),
+				"_tool_use_id":     toolUse.id,
+			}
+		}
+	}
+
+	return &ai.ToolRequest{
+		Name:  toolUse.name,
+		Input: toolInput,
+		Ref:   toolUse.id,
+	}
+}
+
 // convertResponse converts Bedrock response to Genkit format
 func (b *Bedrock) convertResponse(response *bedrockruntime.ConverseOutput, originalInput *ai.ModelRequest) *ai.ModelResponse {
 	// Initialize response
@@ -1152,15 +1539,7 @@ func (b *Bedrock) convertResponse(response *bedrockruntime.ConverseOutput, origi
 	modelResponse.FinishReason = convertStopReasonToGenkit(response.StopReason)
 
 	// Extract usage information (if available in the API)
-	if response.Usage != nil {
-		// Map AWS Bedrock TokenUsage to Genkit GenerationUsage
-		modelResponse.Usage = &ai.GenerationUsage{
-			InputTokens:         int(aws.ToInt32(response.Usage.InputTokens)),
-			OutputTokens:        int(aws.ToInt32(response.Usage.OutputTokens)),
-			TotalTokens:         int(aws.ToInt32(response.Usage.TotalTokens)),
-			CachedContentTokens: int(aws.ToInt32(response.Usage.CacheReadInputTokens)),
-		}
-	}
+	modelResponse.Usage = convertUsage(response.Usage)
 
 	// If no content was extracted, add placeholder
 	if len(modelResponse.Message.Content) == 0 {
@@ -1476,12 +1855,59 @@ func NewArraySchema(itemSchema map[string]interface{}, description string) map[s
 
 // Helper functions
 
+// setResponseMetadata records plugin metadata, such as the region that served the request,
+// in the Custom field of the response.
+func setResponseMetadata(resp *ai.ModelResponse, key string, value any) {
+	custom, ok := resp.Custom.(map[string]any)
+	if !ok {
+		custom = make(map[string]any)
+		resp.Custom = custom
+	}
+	custom[key] = value
+}
+
+// outputText returns the concatenated text blocks of a Converse output message.
+func outputText(output types.ConverseOutput) string {
+	msgMember, ok := output.(*types.ConverseOutputMemberMessage)
+	if !ok {
+		return ""
+	}
+	var sb strings.Builder
+	for _, block := range msgMember.Value.Content {
+		if text, ok := block.(*types.ContentBlockMemberText); ok {
+			sb.WriteString(text.Value)
+		}
+	}
+	return sb.String()
+}
+
+// convertUsage maps AWS Bedrock TokenUsage to Genkit GenerationUsage
+func convertUsage(usage *types.TokenUsage) *ai.GenerationUsage {
+	if usage == nil {
+		return nil
+	}
+	converted := &ai.GenerationUsage{
+		InputTokens:         int(aws.ToInt32(usage.InputTokens)),
+		OutputTokens:        int(aws.ToInt32(usage.OutputTokens)),
+		TotalTokens:         int(aws.ToInt32(usage.TotalTokens)),
+		CachedContentTokens: int(aws.ToInt32(usage.CacheReadInputTokens)),
+	}
+	// Report cache reads and writes with their Bedrock names, as writes have no Genkit field
+	if read, write := aws.ToInt32(usage.CacheReadInputTokens), aws.ToInt32(usage.CacheWriteInputTokens); read > 0 || write > 0 {
+		converted.Custom = map[string]float64{
+			"cacheReadInputTokens":  float64(read),
+			"cacheWriteInputTokens": float64(write),
+		}
+	}
+	return converted
+}
+
 // convertStopReasonToGenkit converts Bedrock stop reason to Genkit finish reason
 func convertStopReasonToGenkit(stopReason types.StopReason) ai.FinishReason {
 	switch stopReason {
 	case types.StopReasonEndTurn:
 		return ai.FinishReasonStop
-	case types.StopReasonMaxTokens:
+	case types.StopReasonMaxTokens, types.StopReasonModelContextWindowExceeded:
 		return ai.FinishReasonLength
 	case types.StopReasonStopSequence:
 		return ai.FinishReasonStop
@@ -1635,23 +2061,3 @@ func DefineCommonEmbedders(b *Bedrock, g *genkit.Genkit) map[string]ai.Embedder
 
 	return embedders
 }
-
-// NewCachePointPart creates and returns a new ai.Part instance representing a cache point part
-// with the default cache point type. A cache point should be inserted after a big static prompt
-// that is reused across multiple requests to optimize token usage.
-func NewCachePointPart() *ai.Part {
-	return ai.NewCustomPart(map[string]any{
-		bedrockCachePointTypeKey: types.CachePointTypeDefault,
-	})
-}
-
-// CachePointType retrieves the CachePointType value from the Custom field of the given ai.Part.
-// It returns the CachePointType and a boolean indicating whether the value was found and successfully asserted.
-func CachePointType(part *ai.Part) (types.CachePointType, bool) {
-	cachePointTypeVal, ok := part.Custom[bedrockCachePointTypeKey]
-	if !ok {
-		return "", false
-	}
-	cpt, ok := cachePointTypeVal.(types.CachePointType)
-	return cpt, ok
-}
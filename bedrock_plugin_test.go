@@ -0,0 +1,326 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+	"github.com/xavidop/genkit-aws-bedrock-go/bedrocktest"
+)
+
+const testModel = "anthropic.claude-3-5-haiku-20241022-v1:0"
+
+// newTestPlugin initializes Genkit with the plugin, filling in a static AWS config
+// so no credentials or network access are needed.
+func newTestPlugin(t *testing.T, b *bedrock.Bedrock) *genkit.Genkit {
+	t.Helper()
+	if b.AWSConfig == nil {
+		b.AWSConfig = &aws.Config{Region: "us-east-1"}
+	}
+	if b.Logger == nil {
+		b.Logger = slog.New(slog.DiscardHandler)
+	}
+	return genkit.Init(context.Background(), genkit.WithPlugins(b))
+}
+
+// defineTestModel returns a text model backed by the fake client.
+func defineTestModel(t *testing.T, fake *bedrocktest.Client, modelID string) (*genkit.Genkit, ai.Model) {
+	t.Helper()
+	b := &bedrock.Bedrock{Client: fake}
+	g := newTestPlugin(t, b)
+	return g, b.DefineModel(g, bedrock.ModelDefinition{Name: modelID, Type: "chat"}, nil)
+}
+
+func TestGenerateText(t *testing.T) {
+	fake := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("Hello there!"))
+	g, m := defineTestModel(t, fake, testModel)
+
+	resp, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithSystem("Be brief."),
+		ai.WithPrompt("Say hello"),
+		ai.WithConfig(map[string]any{"maxOutputTokens": 100, "temperature": 0.5}),
+	)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	if got := resp.Text(); got != "Hello there!" {
+		t.Errorf("Text() = %q, want %q", got, "Hello there!")
+	}
+	if resp.FinishReason != ai.FinishReasonStop {
+		t.Errorf("FinishReason = %q, want %q", resp.FinishReason, ai.FinishReasonStop)
+	}
+	if resp.Usage == nil || resp.Usage.InputTokens != 10 || resp.Usage.OutputTokens != 5 {
+		t.Errorf("Usage = %+v, want 10 input and 5 output tokens", resp.Usage)
+	}
+
+	inputs := fake.ConverseInputs()
+	if len(inputs) != 1 {
+		t.Fatalf("got %d Converse calls, want 1", len(inputs))
+	}
+	in := inputs[0]
+	if got := aws.ToString(in.ModelId); got != testModel {
+		t.Errorf("ModelId = %q, want %q", got, testModel)
+	}
+	if len(in.System) != 1 {
+		t.Fatalf("got %d system blocks, want 1", len(in.System))
+	}
+	if text, ok := in.System[0].(*types.SystemContentBlockMemberText); !ok || text.Value != "Be brief." {
+		t.Errorf("System = %#v, want the system prompt", in.System[0])
+	}
+	if len(in.Messages) != 1 || in.Messages[0].Role != types.ConversationRoleUser {
+		t.Fatalf("Messages = %#v, want a single user message", in.Messages)
+	}
+	if in.InferenceConfig == nil || aws.ToInt32(in.InferenceConfig.MaxTokens) != 100 {
+		t.Errorf("InferenceConfig = %#v, want MaxTokens 100", in.InferenceConfig)
+	}
+}
+
+func TestGenerateToolRequest(t *testing.T) {
+	fake := bedrocktest.NewClient().
+		AddConverse(bedrocktest.ToolUseOutput("tool-1", "getWeather", map[string]any{"city": "Paris", "days": 3}))
+	g, m := defineTestModel(t, fake, testModel)
+	tool := genkit.DefineTool(g, "getWeather", "Returns the weather",
+		func(ctx *ai.ToolContext, input struct {
+			City string `json:"city"`
+			Days int    `json:"days"`
+		}) (string, error) {
+			return "sunny", nil
+		})
+
+	resp, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithPrompt("Weather in Paris?"),
+		ai.WithTools(tool),
+		ai.WithReturnToolRequests(true),
+	)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	requests := resp.ToolRequests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d tool requests, want 1", len(requests))
+	}
+	req := requests[0]
+	if req.Name != "getWeather" || req.Ref != "tool-1" {
+		t.Errorf("tool request = %s (%s), want getWeather (tool-1)", req.Name, req.Ref)
+	}
+	assertToolInput(t, req.Input, `{"city":"Paris","days":3}`)
+
+	in := fake.ConverseInputs()[0]
+	if in.ToolConfig == nil || len(in.ToolConfig.Tools) != 1 {
+		t.Fatalf("ToolConfig = %#v, want one tool", in.ToolConfig)
+	}
+}
+
+func TestGenerateStream(t *testing.T) {
+	stream := bedrocktest.TextStream("Hel", "lo", "!")
+	fake := bedrocktest.NewClient().AddStream(stream)
+	g, m := defineTestModel(t, fake, testModel)
+
+	var chunks []string
+	resp, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithPrompt("Say hello"),
+		ai.WithStreaming(func(ctx context.Context, chunk *ai.ModelResponseChunk) error {
+			chunks = append(chunks, chunk.Text())
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	if got := resp.Text(); got != "Hello!" {
+		t.Errorf("Text() = %q, want %q", got, "Hello!")
+	}
+	if len(chunks) != 3 {
+		t.Errorf("got %d chunks %q, want 3", len(chunks), chunks)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 15 {
+		t.Errorf("Usage = %+v, want 15 total tokens", resp.Usage)
+	}
+	if !stream.Closed() {
+		t.Error("stream was not closed")
+	}
+	if n := len(fake.ConverseStreamInputs()); n != 1 {
+		t.Errorf("got %d ConverseStream calls, want 1", n)
+	}
+}
+
+func TestGenerateStreamReasoning(t *testing.T) {
+	fake := bedrocktest.NewClient().AddStream(&bedrocktest.Stream{
+		Events: []types.ConverseStreamOutput{
+			bedrocktest.MessageStart(),
+			bedrocktest.ReasoningDelta(0, "The user wants a greeting."),
+			bedrocktest.BlockStop(0),
+			bedrocktest.TextDelta(1, "Hello!"),
+			bedrocktest.BlockStop(1),
+			bedrocktest.MessageStop(types.StopReasonEndTurn),
+			bedrocktest.Metadata(bedrocktest.Usage(20, 10)),
+		},
+	})
+	g, m := defineTestModel(t, fake, testModel)
+
+	resp, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithPrompt("Say hello"),
+		ai.WithStreaming(func(ctx context.Context, chunk *ai.ModelResponseChunk) error { return nil }),
+	)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	if got := resp.Text(); got != "Hello!" {
+		t.Errorf("Text() = %q, want reasoning kept out of the text", got)
+	}
+	if resp.FinishReason != ai.FinishReasonStop {
+		t.Errorf("FinishReason = %q, want %q", resp.FinishReason, ai.FinishReasonStop)
+	}
+}
+
+func TestGenerateStreamToolUse(t *testing.T) {
+	fake := bedrocktest.NewClient().AddStream(&bedrocktest.Stream{
+		Events: []types.ConverseStreamOutput{
+			bedrocktest.MessageStart(),
+			bedrocktest.TextDelta(0, "Let me check."),
+			bedrocktest.BlockStop(0),
+			bedrocktest.ToolUseStart(1, "tool-1", "getWeather"),
+			bedrocktest.ToolUseDelta(1, `{"city":`),
+			bedrocktest.ToolUseDelta(1, `"Paris"}`),
+			bedrocktest.BlockStop(1),
+			bedrocktest.ToolUseStart(2, "tool-2", "getWeather"),
+			bedrocktest.BlockStop(2),
+			bedrocktest.MessageStop(types.StopReasonToolUse),
+			bedrocktest.Metadata(bedrocktest.Usage(20, 10)),
+		},
+	})
+	g, m := defineTestModel(t, fake, testModel)
+	tool := genkit.DefineTool(g, "getWeather", "Returns the weather",
+		func(ctx *ai.ToolContext, input struct {
+			City string `json:"city"`
+		}) (string, error) {
+			return "sunny", nil
+		})
+
+	var streamedTools int
+	resp, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithPrompt("Weather in Paris?"),
+		ai.WithTools(tool),
+		ai.WithReturnToolRequests(true),
+		ai.WithStreaming(func(ctx context.Context, chunk *ai.ModelResponseChunk) error {
+			for _, part := range chunk.Content {
+				if part.IsToolRequest() {
+					streamedTools++
+				}
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	if got := resp.Text(); got != "Let me check." {
+		t.Errorf("Text() = %q, want the text before the tool calls", got)
+	}
+	if streamedTools != 2 {
+		t.Errorf("streamed %d tool requests, want 2", streamedTools)
+	}
+	requests := resp.ToolRequests()
+	if len(requests) != 2 {
+		t.Fatalf("ToolRequests() = %+v, want 2 tool requests", requests)
+	}
+	if requests[0].Name != "getWeather" || requests[0].Ref != "tool-1" {
+		t.Errorf("ToolRequests()[0] = %+v, want getWeather (tool-1)", requests[0])
+	}
+	assertToolInput(t, requests[0].Input, `{"city":"Paris"}`)
+	if requests[1].Ref != "tool-2" {
+		t.Errorf("ToolRequests()[1].Ref = %q, want tool-2", requests[1].Ref)
+	}
+	assertToolInput(t, requests[1].Input, `{}`)
+}
+
+func TestEmbed(t *testing.T) {
+	fake := bedrocktest.NewClient().AddInvokeModel([]byte(`{"embedding":[0.1,0.2,0.3]}`))
+	b := &bedrock.Bedrock{Client: fake}
+	g := newTestPlugin(t, b)
+	embedder := b.DefineEmbedder(g, "amazon.titan-embed-text-v2:0")
+
+	resp, err := genkit.Embed(context.Background(), g,
+		ai.WithEmbedder(embedder),
+		ai.WithTextDocs("hello"),
+	)
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+
+	if len(resp.Embeddings) != 1 || len(resp.Embeddings[0].Embedding) != 3 {
+		t.Fatalf("Embeddings = %+v, want one 3-dimensional embedding", resp.Embeddings)
+	}
+
+	inputs := fake.InvokeModelInputs()
+	if len(inputs) != 1 {
+		t.Fatalf("got %d InvokeModel calls, want 1", len(inputs))
+	}
+	var body map[string]any
+	if err := json.Unmarshal(inputs[0].Body, &body); err != nil {
+		t.Fatalf("request body: %v", err)
+	}
+	if body["inputText"] != "hello" {
+		t.Errorf("request body = %v, want inputText %q", body, "hello")
+	}
+}
+
+// assertToolInput compares a tool request input with the expected JSON.
+func assertToolInput(t *testing.T, input any, want string) {
+	t.Helper()
+	got, err := json.Marshal(input)
+	if err != nil {
+		t.Fatalf("tool input: %v", err)
+	}
+	var gotValue, wantValue any
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := jsonString(t, gotValue), jsonString(t, wantValue); g != w {
+		t.Errorf("tool input = %s, want %s", g, w)
+	}
+}
+
+func jsonString(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,289 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package bedrocktest provides an in-process fake of the Bedrock Runtime API, so code
+// using the bedrock plugin can be tested without AWS.
+//
+//	fake := bedrocktest.NewClient()
+//	fake.AddConverse(bedrocktest.TextOutput("Hello!"))
+//
+//	plugin := &bedrock.Bedrock{Region: "us-east-1", Client: fake}
+//	g := genkit.Init(ctx, genkit.WithPlugins(plugin))
+package bedrocktest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+)
+
+// ErrNoResponse is returned by calls for which no response was scripted.
+var ErrNoResponse = errors.New("bedrocktest: no response scripted")
+
+// Client is a programmable fake Bedrock Runtime client. Responses are scripted per
+// operation and returned in the order they were added; every request is recorded.
+type Client struct {
+	mu       sync.Mutex
+	converse []result[*bedrockruntime.ConverseOutput]
+	streams  []result[*Stream]
+	invokes  []result[*bedrockruntime.InvokeModelOutput]
+	counts   []result[*bedrockruntime.CountTokensOutput]
+
+	converseInputs []*bedrockruntime.ConverseInput
+	streamInputs   []*bedrockruntime.ConverseStreamInput
+	invokeInputs   []*bedrockruntime.InvokeModelInput
+	countInputs    []*bedrockruntime.CountTokensInput
+}
+
+var _ bedrock.BedrockClient = (*Client)(nil)
+
+// result is a scripted response or error.
+type result[T any] struct {
+	value T
+	err   error
+}
+
+// NewClient returns a fake client with no scripted responses.
+func NewClient() *Client {
+	return &Client{}
+}
+
+// AddConverse scripts the response of the next Converse call.
+func (c *Client) AddConverse(out *bedrockruntime.ConverseOutput) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.converse = append(c.converse, result[*bedrockruntime.ConverseOutput]{value: out})
+	return c
+}
+
+// AddConverseError scripts the next Converse call to fail with err.
+func (c *Client) AddConverseError(err error) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.converse = append(c.converse, result[*bedrockruntime.ConverseOutput]{err: err})
+	return c
+}
+
+// AddStream scripts the events of the next ConverseStream call.
+func (c *Client) AddStream(stream *Stream) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.streams = append(c.streams, result[*Stream]{value: stream})
+	return c
+}
+
+// AddStreamError scripts the next ConverseStream call to fail with err before any event.
+func (c *Client) AddStreamError(err error) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.streams = append(c.streams, result[*Stream]{err: err})
+	return c
+}
+
+// AddInvokeModel scripts the response body of the next InvokeModel call.
+func (c *Client) AddInvokeModel(body []byte) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := &bedrockruntime.InvokeModelOutput{Body: body, ContentType: aws.String("application/json")}
+	c.invokes = append(c.invokes, result[*bedrockruntime.InvokeModelOutput]{value: out})
+	return c
+}
+
+// AddInvokeModelError scripts the next InvokeModel call to fail with err.
+func (c *Client) AddInvokeModelError(err error) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invokes = append(c.invokes, result[*bedrockruntime.InvokeModelOutput]{err: err})
+	return c
+}
+
+// AddCountTokens scripts the input token count of the next CountTokens call.
+func (c *Client) AddCountTokens(inputTokens int32) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := &bedrockruntime.CountTokensOutput{InputTokens: aws.Int32(inputTokens)}
+	c.counts = append(c.counts, result[*bedrockruntime.CountTokensOutput]{value: out})
+	return c
+}
+
+// AddCountTokensError scripts the next CountTokens call to fail with err.
+func (c *Client) AddCountTokensError(err error) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts = append(c.counts, result[*bedrockruntime.CountTokensOutput]{err: err})
+	return c
+}
+
+// Converse implements bedrock.BedrockClient.
+func (c *Client) Converse(ctx context.Context, input *bedrockruntime.ConverseInput, _ ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.converseInputs = append(c.converseInputs, input)
+	r, err := next(&c.converse, "Converse")
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return r.value, r.err
+}
+
+// ConverseStream implements bedrock.BedrockClient.
+func (c *Client) ConverseStream(ctx context.Context, input *bedrockruntime.ConverseStreamInput, _ ...func(*bedrockruntime.Options)) (*bedrock.ConverseStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.streamInputs = append(c.streamInputs, input)
+	r, err := next(&c.streams, "ConverseStream")
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &bedrock.ConverseStream{Reader: r.value.open()}, nil
+}
+
+// InvokeModel implements bedrock.BedrockClient.
+func (c *Client) InvokeModel(ctx context.Context, input *bedrockruntime.InvokeModelInput, _ ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.invokeInputs = append(c.invokeInputs, input)
+	r, err := next(&c.invokes, "InvokeModel")
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return r.value, r.err
+}
+
+// CountTokens implements bedrock.BedrockClient.
+func (c *Client) CountTokens(ctx context.Context, input *bedrockruntime.CountTokensInput, _ ...func(*bedrockruntime.Options)) (*bedrockruntime.CountTokensOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.countInputs = append(c.countInputs, input)
+	r, err := next(&c.counts, "CountTokens")
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return r.value, r.err
+}
+
+// ConverseInputs returns the requests received by Converse.
+func (c *Client) ConverseInputs() []*bedrockruntime.ConverseInput {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*bedrockruntime.ConverseInput(nil), c.converseInputs...)
+}
+
+// ConverseStreamInputs returns the requests received by ConverseStream.
+func (c *Client) ConverseStreamInputs() []*bedrockruntime.ConverseStreamInput {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*bedrockruntime.ConverseStreamInput(nil), c.streamInputs...)
+}
+
+// InvokeModelInputs returns the requests received by InvokeModel.
+func (c *Client) InvokeModelInputs() []*bedrockruntime.InvokeModelInput {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*bedrockruntime.InvokeModelInput(nil), c.invokeInputs...)
+}
+
+// CountTokensInputs returns the requests received by CountTokens.
+func (c *Client) CountTokensInputs() []*bedrockruntime.CountTokensInput {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*bedrockruntime.CountTokensInput(nil), c.countInputs...)
+}
+
+// Pending returns the number of scripted responses that have not been used yet.
+func (c *Client) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.converse) + len(c.streams) + len(c.invokes) + len(c.counts)
+}
+
+// next pops the next scripted result of an operation.
+func next[T any](queue *[]result[T], operation string) (result[T], error) {
+	if len(*queue) == 0 {
+		return result[T]{}, fmt.Errorf("%w for %s", ErrNoResponse, operation)
+	}
+	r := (*queue)[0]
+	*queue = (*queue)[1:]
+	return r, nil
+}
+
+// Stream is a scripted ConverseStream response.
+type Stream struct {
+	Events []types.ConverseStreamOutput // Events delivered in order
+	Err    error                        // Error reported once the events are delivered, like a mid-stream exception
+
+	closed atomic.Bool
+}
+
+// Closed reports whether the plugin closed the stream.
+func (s *Stream) Closed() bool {
+	return s.closed.Load()
+}
+
+// open returns a reader delivering the stream events.
+func (s *Stream) open() bedrockruntime.ConverseStreamOutputReader {
+	events := make(chan types.ConverseStreamOutput, len(s.Events))
+	for _, event := range s.Events {
+		events <- event
+	}
+	close(events)
+	return &streamReader{stream: s, events: events}
+}
+
+// streamReader implements bedrockruntime.ConverseStreamOutputReader over a Stream.
+type streamReader struct {
+	stream *Stream
+	events chan types.ConverseStreamOutput
+}
+
+func (r *streamReader) Events() <-chan types.ConverseStreamOutput {
+	return r.events
+}
+
+func (r *streamReader) Close() error {
+	r.stream.closed.Store(true)
+	return nil
+}
+
+func (r *streamReader) Err() error {
+	return r.stream.Err
+}
@@ -0,0 +1,492 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrocktest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+)
+
+// RecordEnv is the environment variable that switches NewTestRecorder to recording.
+const RecordEnv = "BEDROCK_RECORD"
+
+// cassetteVersion is the version of the cassette file format.
+const cassetteVersion = 1
+
+// eventStreamContentType is the content type of ConverseStream responses.
+const eventStreamContentType = "application/vnd.amazon.eventstream"
+
+// Recorder modes.
+const (
+	ModeReplay Mode = iota // Serve requests from the cassette, failing on unmatched requests
+	ModeRecord             // Send requests to AWS and record the exchanges
+)
+
+// Mode selects whether a Recorder records or replays.
+type Mode int
+
+// ErrUnmatchedRequest is returned in replay mode for requests that are not in the cassette.
+var ErrUnmatchedRequest = errors.New("bedrocktest: no recorded interaction matches the request")
+
+// Headers kept in cassettes. All others, including the signature and security token, are dropped.
+var (
+	recordedRequestHeaders  = []string{"Content-Type", "Accept"}
+	recordedResponseHeaders = []string{"Content-Type", "X-Amzn-Requestid", "X-Amzn-Errortype"}
+)
+
+// Cassette is a recording of Bedrock HTTP exchanges.
+type Cassette struct {
+	Version      int            `json:"version"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+
+	used bool // Whether the interaction was replayed
+}
+
+// RecordedRequest is a recorded Bedrock request.
+type RecordedRequest struct {
+	Method     string            `json:"method"`
+	Operation  string            `json:"operation"`         // Last path segment, e.g. "converse" or "converse-stream"
+	ModelID    string            `json:"modelId,omitempty"` // Model ID, inference profile or ARN from the path
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       json.RawMessage   `json:"body,omitempty"`       // JSON body
+	BodyBase64 string            `json:"bodyBase64,omitempty"` // Non-JSON body
+}
+
+// RecordedResponse is a recorded Bedrock response.
+type RecordedResponse struct {
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       json.RawMessage   `json:"body,omitempty"`       // JSON body
+	BodyBase64 string            `json:"bodyBase64,omitempty"` // Non-JSON body
+	Events     []RecordedEvent   `json:"events,omitempty"`     // Event-stream frames, for ConverseStream
+}
+
+// RecordedEvent is one recorded event-stream frame.
+type RecordedEvent struct {
+	Headers map[string]string `json:"headers"`
+	Payload json.RawMessage   `json:"payload,omitempty"`
+}
+
+// Recorder is an HTTP client for aws.Config.HTTPClient that records Bedrock exchanges to a
+// cassette file, or replays them offline. Requests are matched by operation, model ID and
+// JSON body with keys sorted; identical requests are replayed in recording order.
+//
+//	rec, err := bedrocktest.NewRecorder("testdata/chat.json", bedrocktest.ModeReplay, nil)
+//	plugin := &bedrock.Bedrock{AWSConfig: &aws.Config{Region: "us-east-1", HTTPClient: rec}}
+type Recorder struct {
+	// Redact, if set, is called on each interaction before it is saved, e.g. to scrub
+	// personal data from prompts. In replay mode it is called on each incoming request, with
+	// an empty Response, before matching, so redacted requests match their recording.
+	Redact func(*Interaction)
+
+	path   string
+	mode   Mode
+	client aws.HTTPClient
+
+	mu       sync.Mutex
+	cassette *Cassette
+}
+
+// NewRecorder returns a Recorder for the cassette at path. In ModeRecord requests are sent
+// with client (default: the AWS SDK HTTP client) and Save writes the cassette. In ModeReplay
+// the cassette must exist.
+func NewRecorder(path string, mode Mode, client aws.HTTPClient) (*Recorder, error) {
+	r := &Recorder{
+		path:     path,
+		mode:     mode,
+		client:   client,
+		cassette: &Cassette{Version: cassetteVersion},
+	}
+	if r.client == nil {
+		r.client = awshttp.NewBuildableClient()
+	}
+
+	if mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("bedrocktest: failed to read cassette: %w", err)
+		}
+		if err := json.Unmarshal(data, r.cassette); err != nil {
+			return nil, fmt.Errorf("bedrocktest: failed to parse cassette %s: %w", path, err)
+		}
+		if r.cassette.Version != cassetteVersion {
+			return nil, fmt.Errorf("bedrocktest: unsupported cassette version %d in %s", r.cassette.Version, path)
+		}
+	}
+
+	return r, nil
+}
+
+// NewTestRecorder returns a Recorder for the cassette at path that replays, or records when
+// the BEDROCK_RECORD environment variable is set. A recorded cassette is saved when the test
+// finishes.
+func NewTestRecorder(t testing.TB, path string) *Recorder {
+	t.Helper()
+	mode := ModeReplay
+	if os.Getenv(RecordEnv) != "" {
+		mode = ModeRecord
+	}
+
+	r, err := NewRecorder(path, mode, nil)
+	if err != nil {
+		t.Fatalf("%v (set %s=1 to record it)", err, RecordEnv)
+	}
+	if mode == ModeRecord {
+		t.Cleanup(func() {
+			if err := r.Save(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	return r
+}
+
+// Do implements aws.HTTPClient.
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	recorded := newRecordedRequest(req, body)
+
+	if r.mode == ModeReplay {
+		return r.replay(req, recorded)
+	}
+	return r.record(req, recorded)
+}
+
+// Save writes the recorded cassette.
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("bedrocktest: failed to encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("bedrocktest: failed to create cassette directory: %w", err)
+	}
+	if err := os.WriteFile(r.path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("bedrocktest: failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// Unused returns the recorded interactions that have not been replayed.
+func (r *Recorder) Unused() []*Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []*Interaction
+	for _, i := range r.cassette.Interactions {
+		if !i.used {
+			unused = append(unused, i)
+		}
+	}
+	return unused
+}
+
+// record sends the request and records the exchange.
+func (r *Recorder) record(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Read the whole response, including streams, so it can be recorded before it is returned
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("bedrocktest: failed to read response: %w", err)
+	}
+
+	interaction := &Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    recordHeaders(resp.Header, recordedResponseHeaders),
+		},
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), eventStreamContentType) {
+		if interaction.Response.Events, err = decodeEvents(body); err != nil {
+			return nil, err
+		}
+	} else {
+		interaction.Response.Body, interaction.Response.BodyBase64 = recordBody(body)
+	}
+	if r.Redact != nil {
+		r.Redact(interaction)
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mu.Unlock()
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// replay returns the first unused recorded response matching the request.
+func (r *Recorder) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	if r.Redact != nil {
+		incoming := &Interaction{Request: recorded}
+		r.Redact(incoming)
+		recorded = incoming.Request
+	}
+
+	r.mu.Lock()
+	var match *Interaction
+	for _, i := range r.cassette.Interactions {
+		if !i.used && i.Request.matches(recorded) {
+			match = i
+			break
+		}
+	}
+	if match != nil {
+		match.used = true
+	}
+	r.mu.Unlock()
+
+	if match == nil {
+		return nil, &UnmatchedRequestError{Request: recorded}
+	}
+
+	resp := &http.Response{
+		StatusCode: match.Response.StatusCode,
+		Status:     fmt.Sprintf("%d %s", match.Response.StatusCode, http.StatusText(match.Response.StatusCode)),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Request:    req,
+	}
+	for name, value := range match.Response.Headers {
+		resp.Header.Set(name, value)
+	}
+
+	var body []byte
+	var err error
+	if match.Response.Events != nil {
+		body, err = encodeEvents(match.Response.Events)
+	} else {
+		body, err = replayBody(match.Response.Body, match.Response.BodyBase64)
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return resp, nil
+}
+
+// UnmatchedRequestError reports a request that is not in the cassette. It is not retried
+// by the AWS SDK.
+type UnmatchedRequestError struct {
+	Request RecordedRequest
+}
+
+// Error implements the error interface.
+func (e *UnmatchedRequestError) Error() string {
+	return fmt.Sprintf("%v: %s %s for model %q with body %s (record it again with %s=1)",
+		ErrUnmatchedRequest, e.Request.Method, e.Request.Operation, e.Request.ModelID, e.Request.Body, RecordEnv)
+}
+
+// Unwrap returns ErrUnmatchedRequest.
+func (e *UnmatchedRequestError) Unwrap() error {
+	return ErrUnmatchedRequest
+}
+
+// RetryableError tells the AWS SDK not to retry the request.
+func (e *UnmatchedRequestError) RetryableError() bool {
+	return false
+}
+
+// matches reports whether two recorded requests are the same call.
+func (r RecordedRequest) matches(other RecordedRequest) bool {
+	return r.Method == other.Method &&
+		r.Operation == other.Operation &&
+		r.ModelID == other.ModelID &&
+		bytes.Equal(normalizeJSON(r.Body), normalizeJSON(other.Body)) &&
+		r.BodyBase64 == other.BodyBase64
+}
+
+// newRecordedRequest records the parts of a request used for matching.
+func newRecordedRequest(req *http.Request, body []byte) RecordedRequest {
+	recorded := RecordedRequest{
+		Method:  req.Method,
+		Headers: recordHeaders(req.Header, recordedRequestHeaders),
+	}
+
+	// Paths look like /model/{modelId}/{operation}, with slashes in ARNs escaped
+	segments := strings.Split(strings.TrimPrefix(req.URL.EscapedPath(), "/"), "/")
+	if len(segments) > 0 {
+		recorded.Operation = segments[len(segments)-1]
+	}
+	if len(segments) == 3 && segments[0] == "model" {
+		recorded.ModelID, _ = url.PathUnescape(segments[1])
+	}
+
+	recorded.Body, recorded.BodyBase64 = recordBody(body)
+	return recorded
+}
+
+// readRequestBody reads the request body, leaving it in place for the request to be sent.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("bedrocktest: failed to read request: %w", err)
+	}
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+// recordHeaders returns the allowed headers.
+func recordHeaders(header http.Header, allowed []string) map[string]string {
+	headers := map[string]string{}
+	for _, name := range allowed {
+		if value := header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+	return headers
+}
+
+// recordBody stores JSON bodies as JSON, so cassettes diff well, and other bodies as base64.
+func recordBody(body []byte) (json.RawMessage, string) {
+	if len(body) == 0 {
+		return nil, ""
+	}
+	if json.Valid(body) {
+		return normalizeJSON(body), ""
+	}
+	return nil, base64.StdEncoding.EncodeToString(body)
+}
+
+// replayBody returns a recorded body.
+func replayBody(body json.RawMessage, bodyBase64 string) ([]byte, error) {
+	if bodyBase64 != "" {
+		data, err := base64.StdEncoding.DecodeString(bodyBase64)
+		if err != nil {
+			return nil, fmt.Errorf("bedrocktest: invalid recorded body: %w", err)
+		}
+		return data, nil
+	}
+	return body, nil
+}
+
+// normalizeJSON re-encodes JSON compactly with object keys sorted.
+func normalizeJSON(data []byte) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return data
+	}
+	normalized, err := json.Marshal(v)
+	if err != nil {
+		return data
+	}
+	return normalized
+}
+
+// decodeEvents decodes an event-stream response into its frames. The random "p" padding
+// field Bedrock adds to event payloads is dropped.
+func decodeEvents(body []byte) ([]RecordedEvent, error) {
+	decoder := eventstream.NewDecoder()
+	reader := bytes.NewReader(body)
+	events := []RecordedEvent{}
+	for reader.Len() > 0 {
+		msg, err := decoder.Decode(reader, nil)
+		if err != nil {
+			return nil, fmt.Errorf("bedrocktest: failed to decode event stream: %w", err)
+		}
+
+		event := RecordedEvent{Headers: map[string]string{}}
+		for _, h := range msg.Headers {
+			event.Headers[h.Name] = h.Value.String()
+		}
+
+		var payload map[string]json.RawMessage
+		if json.Unmarshal(msg.Payload, &payload) == nil {
+			delete(payload, "p")
+			data, err := json.Marshal(payload)
+			if err != nil {
+				return nil, fmt.Errorf("bedrocktest: failed to record event: %w", err)
+			}
+			event.Payload = data
+		} else if len(msg.Payload) > 0 {
+			return nil, fmt.Errorf("bedrocktest: unsupported non-JSON event payload")
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// encodeEvents encodes recorded frames as an event-stream response body.
+func encodeEvents(events []RecordedEvent) ([]byte, error) {
+	encoder := eventstream.NewEncoder()
+	var buf bytes.Buffer
+	for _, event := range events {
+		var msg eventstream.Message
+		names := make([]string, 0, len(event.Headers))
+		for name := range event.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			msg.Headers.Set(name, eventstream.StringValue(event.Headers[name]))
+		}
+		msg.Payload = event.Payload
+		if err := encoder.Encode(&buf, msg); err != nil {
+			return nil, fmt.Errorf("bedrocktest: failed to encode event: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
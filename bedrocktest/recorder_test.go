@@ -0,0 +1,224 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrocktest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+	"github.com/xavidop/genkit-aws-bedrock-go/bedrocktest"
+)
+
+const testModel = "anthropic.claude-3-5-haiku-20241022-v1:0"
+
+// newBedrockServer returns an HTTP server answering Converse and ConverseStream requests
+// the way Bedrock does.
+func newBedrockServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amzn-Requestid", "req-1")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/converse"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"output":{"message":{"role":"assistant","content":[{"text":"Hello!"}]}},` +
+				`"stopReason":"end_turn","usage":{"inputTokens":3,"outputTokens":2,"totalTokens":5}}`))
+		case strings.HasSuffix(r.URL.Path, "/converse-stream"):
+			w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+			var buf bytes.Buffer
+			encoder := eventstream.NewEncoder()
+			for _, e := range []struct{ eventType, payload string }{
+				{"messageStart", `{"role":"assistant","p":"abcd"}`},
+				{"contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"Hel"},"p":"abcdefgh"}`},
+				{"contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"lo!"},"p":"ab"}`},
+				{"contentBlockStop", `{"contentBlockIndex":0}`},
+				{"messageStop", `{"stopReason":"end_turn"}`},
+				{"metadata", `{"usage":{"inputTokens":3,"outputTokens":2,"totalTokens":5},"metrics":{"latencyMs":10}}`},
+			} {
+				msg := eventstream.Message{Payload: []byte(e.payload)}
+				msg.Headers.Set(":message-type", eventstream.StringValue("event"))
+				msg.Headers.Set(":event-type", eventstream.StringValue(e.eventType))
+				msg.Headers.Set(":content-type", eventstream.StringValue("application/json"))
+				if err := encoder.Encode(&buf, msg); err != nil {
+					t.Error(err)
+				}
+			}
+			w.Write(buf.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// generate runs a sync and a streaming request through the plugin using the recorder.
+func generate(t *testing.T, rec *bedrocktest.Recorder, endpoint string) (string, string) {
+	t.Helper()
+	plugin := &bedrock.Bedrock{
+		AWSConfig: &aws.Config{
+			Region:       "us-east-1",
+			BaseEndpoint: aws.String(endpoint),
+			HTTPClient:   rec,
+			Credentials:  credentials.NewStaticCredentialsProvider("AKID", "SECRET", "TOKEN"),
+		},
+		Logger: slog.New(slog.DiscardHandler),
+	}
+	ctx := context.Background()
+	g := genkit.Init(ctx, genkit.WithPlugins(plugin))
+	m := plugin.DefineModel(g, bedrock.ModelDefinition{Name: testModel, Type: "chat"}, nil)
+
+	resp, err := genkit.Generate(ctx, g, ai.WithModel(m), ai.WithPrompt("Say hello"))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	streamed, err := genkit.Generate(ctx, g,
+		ai.WithModel(m),
+		ai.WithPrompt("Say hello slowly"),
+		ai.WithStreaming(func(context.Context, *ai.ModelResponseChunk) error { return nil }),
+	)
+	if err != nil {
+		t.Fatalf("Generate stream: %v", err)
+	}
+	return resp.Text(), streamed.Text()
+}
+
+func TestRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "hello.json")
+	server := newBedrockServer(t)
+
+	rec, err := bedrocktest.NewRecorder(path, bedrocktest.ModeRecord, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, streamed := generate(t, rec, server.URL)
+	if text != "Hello!" || streamed != "Hello!" {
+		t.Fatalf("recorded responses = %q, %q, want Hello!", text, streamed)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"AKID", "TOKEN", "Authorization", `"p"`} {
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("cassette contains %s:\n%s", secret, data)
+		}
+	}
+
+	// Replay against a closed server, so any request that isn't replayed fails
+	server.Close()
+	replay, err := bedrocktest.NewRecorder(path, bedrocktest.ModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, streamed = generate(t, replay, server.URL)
+	if text != "Hello!" || streamed != "Hello!" {
+		t.Errorf("replayed responses = %q, %q, want Hello!", text, streamed)
+	}
+	if unused := replay.Unused(); len(unused) != 0 {
+		t.Errorf("%d interactions were not replayed", len(unused))
+	}
+}
+
+func TestRecordReplayRedact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redacted.json")
+	server := newBedrockServer(t)
+	redact := func(i *bedrocktest.Interaction) {
+		i.Request.Body = bytes.ReplaceAll(i.Request.Body, []byte("Say hello"), []byte("[REDACTED]"))
+	}
+
+	rec, err := bedrocktest.NewRecorder(path, bedrocktest.ModeRecord, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Redact = redact
+	generate(t, rec, server.URL)
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("Say hello")) || !bytes.Contains(data, []byte("[REDACTED]")) {
+		t.Errorf("cassette prompts are not redacted:\n%s", data)
+	}
+
+	// The unredacted requests match the redacted recording
+	server.Close()
+	replay, err := bedrocktest.NewRecorder(path, bedrocktest.ModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay.Redact = redact
+	text, streamed := generate(t, replay, server.URL)
+	if text != "Hello!" || streamed != "Hello!" {
+		t.Errorf("replayed responses = %q, %q, want Hello!", text, streamed)
+	}
+	if unused := replay.Unused(); len(unused) != 0 {
+		t.Errorf("%d interactions were not replayed", len(unused))
+	}
+}
+
+func TestReplayUnmatchedRequest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.json")
+	if err := os.WriteFile(path, []byte(`{"version":1,"interactions":[]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := bedrocktest.NewRecorder(path, bedrocktest.ModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plugin := &bedrock.Bedrock{
+		AWSConfig: &aws.Config{
+			Region:      "us-east-1",
+			HTTPClient:  rec,
+			Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		},
+		Logger: slog.New(slog.DiscardHandler),
+	}
+	ctx := context.Background()
+	g := genkit.Init(ctx, genkit.WithPlugins(plugin))
+	m := plugin.DefineModel(g, bedrock.ModelDefinition{Name: testModel, Type: "chat"}, nil)
+
+	_, err = genkit.Generate(ctx, g, ai.WithModel(m), ai.WithPrompt("Not recorded"))
+	if !errors.Is(err, bedrocktest.ErrUnmatchedRequest) {
+		t.Fatalf("error = %v, want ErrUnmatchedRequest", err)
+	}
+	if !strings.Contains(err.Error(), testModel) {
+		t.Errorf("error %q does not name the model", err)
+	}
+}
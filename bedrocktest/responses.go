@@ -0,0 +1,208 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrocktest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	smithydocumentjson "github.com/aws/smithy-go/document/json"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// Usage returns token usage with the given input and output token counts.
+func Usage(inputTokens, outputTokens int32) *types.TokenUsage {
+	total := inputTokens + outputTokens
+	return &types.TokenUsage{
+		InputTokens:  &inputTokens,
+		OutputTokens: &outputTokens,
+		TotalTokens:  &total,
+	}
+}
+
+// Output returns a Converse response with the given content blocks and stop reason,
+// reporting 10 input and 5 output tokens.
+func Output(stopReason types.StopReason, content ...types.ContentBlock) *bedrockruntime.ConverseOutput {
+	return &bedrockruntime.ConverseOutput{
+		Output: &types.ConverseOutputMemberMessage{
+			Value: types.Message{
+				Role:    types.ConversationRoleAssistant,
+				Content: content,
+			},
+		},
+		StopReason: stopReason,
+		Usage:      Usage(10, 5),
+	}
+}
+
+// TextOutput returns a Converse response with a single text block.
+func TextOutput(text string) *bedrockruntime.ConverseOutput {
+	return Output(types.StopReasonEndTurn, TextBlock(text))
+}
+
+// ToolUseOutput returns a Converse response requesting a single tool call.
+func ToolUseOutput(id, name string, input any) *bedrockruntime.ConverseOutput {
+	return Output(types.StopReasonToolUse, ToolUseBlock(id, name, input))
+}
+
+// TextBlock returns a text content block.
+func TextBlock(text string) types.ContentBlock {
+	return &types.ContentBlockMemberText{Value: text}
+}
+
+// ToolUseBlock returns a tool use content block with input encoded as a document.
+func ToolUseBlock(id, name string, input any) types.ContentBlock {
+	return &types.ContentBlockMemberToolUse{
+		Value: types.ToolUseBlock{
+			ToolUseId: &id,
+			Name:      &name,
+			Input:     newResponseDocument(input),
+		},
+	}
+}
+
+// responseDocument is a document that decodes like one deserialized from a Bedrock response.
+// Documents made with document.NewLazyDocument only support marshaling.
+type responseDocument struct {
+	document.Interface
+}
+
+func newResponseDocument(v any) document.Interface {
+	return responseDocument{document.NewLazyDocument(v)}
+}
+
+// UnmarshalSmithyDocument decodes the document into v.
+func (d responseDocument) UnmarshalSmithyDocument(v any) error {
+	data, err := d.MarshalSmithyDocument()
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	return smithydocumentjson.NewDecoder().DecodeJSONInterface(value, v)
+}
+
+// TextStream returns a complete ConverseStream response streaming the text in the given
+// chunks, reporting 10 input and 5 output tokens.
+func TextStream(chunks ...string) *Stream {
+	events := []types.ConverseStreamOutput{MessageStart()}
+	for _, chunk := range chunks {
+		events = append(events, TextDelta(0, chunk))
+	}
+	events = append(events,
+		BlockStop(0),
+		MessageStop(types.StopReasonEndTurn),
+		Metadata(Usage(10, 5)),
+	)
+	return &Stream{Events: events}
+}
+
+// MessageStart returns the event starting an assistant message.
+func MessageStart() types.ConverseStreamOutput {
+	return &types.ConverseStreamOutputMemberMessageStart{
+		Value: types.MessageStartEvent{Role: types.ConversationRoleAssistant},
+	}
+}
+
+// TextDelta returns an event carrying text for the content block at index.
+func TextDelta(index int32, text string) types.ConverseStreamOutput {
+	return &types.ConverseStreamOutputMemberContentBlockDelta{
+		Value: types.ContentBlockDeltaEvent{
+			ContentBlockIndex: &index,
+			Delta:             &types.ContentBlockDeltaMemberText{Value: text},
+		},
+	}
+}
+
+// ReasoningDelta returns an event carrying reasoning text for the content block at index.
+func ReasoningDelta(index int32, text string) types.ConverseStreamOutput {
+	return &types.ConverseStreamOutputMemberContentBlockDelta{
+		Value: types.ContentBlockDeltaEvent{
+			ContentBlockIndex: &index,
+			Delta: &types.ContentBlockDeltaMemberReasoningContent{
+				Value: &types.ReasoningContentBlockDeltaMemberText{Value: text},
+			},
+		},
+	}
+}
+
+// ToolUseStart returns the event starting a tool call in the content block at index.
+func ToolUseStart(index int32, id, name string) types.ConverseStreamOutput {
+	return &types.ConverseStreamOutputMemberContentBlockStart{
+		Value: types.ContentBlockStartEvent{
+			ContentBlockIndex: &index,
+			Start: &types.ContentBlockStartMemberToolUse{
+				Value: types.ToolUseBlockStart{ToolUseId: &id, Name: &name},
+			},
+		},
+	}
+}
+
+// ToolUseDelta returns an event carrying a fragment of the JSON input of the tool call
+// in the content block at index.
+func ToolUseDelta(index int32, input string) types.ConverseStreamOutput {
+	return &types.ConverseStreamOutputMemberContentBlockDelta{
+		Value: types.ContentBlockDeltaEvent{
+			ContentBlockIndex: &index,
+			Delta: &types.ContentBlockDeltaMemberToolUse{
+				Value: types.ToolUseBlockDelta{Input: &input},
+			},
+		},
+	}
+}
+
+// BlockStop returns the event ending the content block at index.
+func BlockStop(index int32) types.ConverseStreamOutput {
+	return &types.ConverseStreamOutputMemberContentBlockStop{
+		Value: types.ContentBlockStopEvent{ContentBlockIndex: &index},
+	}
+}
+
+// MessageStop returns the event ending the assistant message.
+func MessageStop(stopReason types.StopReason) types.ConverseStreamOutput {
+	return &types.ConverseStreamOutputMemberMessageStop{
+		Value: types.MessageStopEvent{StopReason: stopReason},
+	}
+}
+
+// Metadata returns the event reporting token usage, sent after the message stop.
+func Metadata(usage *types.TokenUsage) types.ConverseStreamOutput {
+	return &types.ConverseStreamOutputMemberMetadata{
+		Value: types.ConverseStreamMetadataEvent{Usage: usage},
+	}
+}
+
+// ResponseError wraps err the way the AWS SDK reports a failed HTTP response, with the
+// status code and AWS request ID.
+func ResponseError(statusCode int, requestID string, err error) error {
+	return &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: statusCode}},
+			Err:      err,
+		},
+		RequestID: requestID,
+	}
+}
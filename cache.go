@@ -0,0 +1,314 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/firebase/genkit/go/ai"
+)
+
+const (
+	bedrockCachePointTypeKey = "bedrockCachePointType"
+	bedrockCachePointTTLKey  = "bedrockCachePointTTL"
+)
+
+const (
+	maxCachePoints        = 4    // Cache points Bedrock accepts per request
+	defaultMinCacheTokens = 1024 // Minimum tokens before a cache point when the catalog has none
+)
+
+// Cache TTLs offered by Bedrock. The extended TTL is only available on some models, see the
+// extendedCacheTTL catalog entry.
+const (
+	DefaultCacheTTL  = 5 * time.Minute
+	ExtendedCacheTTL = time.Hour
+)
+
+// NewCachePointPart creates and returns a new ai.Part instance representing a cache point part
+// with the default cache point type. A cache point should be inserted after a big static prompt
+// that is reused across multiple requests to optimize token usage. Cache point parts hold plain
+// strings, so they survive JSON serialization, e.g. in session stores or flow inputs.
+func NewCachePointPart() *ai.Part {
+	return ai.NewCustomPart(map[string]any{
+		bedrockCachePointTypeKey: string(types.CachePointTypeDefault),
+	})
+}
+
+// CachePointType retrieves the CachePointType value from the Custom field of the given ai.Part.
+// It returns the CachePointType and a boolean indicating whether the part is a cache point. The
+// type is not validated, an unknown type fails the request when it is sent.
+func CachePointType(part *ai.Part) (types.CachePointType, bool) {
+	if part == nil || !part.IsCustom() {
+		return "", false
+	}
+	cachePointTypeVal, ok := part.Custom[bedrockCachePointTypeKey]
+	if !ok {
+		return "", false
+	}
+	switch cpt := cachePointTypeVal.(type) {
+	case types.CachePointType:
+		return cpt, true
+	case string:
+		return types.CachePointType(cpt), true
+	default:
+		return "", true
+	}
+}
+
+// NewCachePointPartWithTTL returns a cache point part whose cache entry lives for ttl,
+// DefaultCacheTTL or ExtendedCacheTTL, instead of the plugin's CacheTTL.
+func NewCachePointPartWithTTL(ttl time.Duration) *ai.Part {
+	part := NewCachePointPart()
+	part.Custom[bedrockCachePointTTLKey] = ttl.String()
+	return part
+}
+
+// CachePointTTL returns the TTL of a cache point part created with NewCachePointPartWithTTL.
+// It returns false if the part has no TTL or it cannot be parsed.
+func CachePointTTL(part *ai.Part) (time.Duration, bool) {
+	ttl, err := cachePointTTL(part)
+	return ttl, err == nil && ttl != 0
+}
+
+// cachePointTTL parses the TTL of a cache point part, or returns 0 if it has none. TTLs are
+// duration strings, but time.Duration values and nanoseconds decoded from JSON are accepted.
+func cachePointTTL(part *ai.Part) (time.Duration, error) {
+	switch ttl := part.Custom[bedrockCachePointTTLKey].(type) {
+	case nil:
+		return 0, nil
+	case string:
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return 0, fmt.Errorf("invalid cache point TTL %q", ttl)
+		}
+		return d, nil
+	case time.Duration:
+		return ttl, nil
+	case float64:
+		return time.Duration(ttl), nil
+	default:
+		return 0, fmt.Errorf("invalid cache point TTL %v", ttl)
+	}
+}
+
+// cachePointBlock converts a cache point part, reporting false if the part is not a cache point
+// and an error if its type or TTL is unknown.
+func (b *Bedrock) cachePointBlock(ctx context.Context, modelName string, caps ModelCapabilities, known bool, part *ai.Part) (types.CachePointBlock, bool, error) {
+	cpt, ok := CachePointType(part)
+	if !ok {
+		return types.CachePointBlock{}, false, nil
+	}
+	if !slices.Contains(cpt.Values(), cpt) {
+		return types.CachePointBlock{}, true, newValidationError(modelName, fmt.Sprintf("unknown cache point type %q", cpt))
+	}
+	ttl, err := cachePointTTL(part)
+	if err != nil {
+		return types.CachePointBlock{}, true, newValidationError(modelName, err.Error())
+	}
+	cachePoint, err := b.cachePoint(ctx, modelName, caps, known, cpt, ttl)
+	return cachePoint, true, err
+}
+
+// cacheTTL converts a cache TTL to the Bedrock value. The default TTL needs no value.
+func cacheTTL(ttl time.Duration) (types.CacheTTL, error) {
+	switch ttl {
+	case 0, DefaultCacheTTL:
+		return "", nil
+	case ExtendedCacheTTL:
+		return types.CacheTTLOneHour, nil
+	default:
+		return "", fmt.Errorf("unsupported cache TTL %s, Bedrock caches for %s or %s", ttl, DefaultCacheTTL, ExtendedCacheTTL)
+	}
+}
+
+// cachePoint builds a cache point with the given TTL, or the plugin's CacheTTL if zero. The
+// extended TTL is dropped with a warning for models known not to support it.
+func (b *Bedrock) cachePoint(ctx context.Context, modelName string, caps ModelCapabilities, known bool, cpt types.CachePointType, ttl time.Duration) (types.CachePointBlock, error) {
+	if ttl == 0 {
+		ttl = b.CacheTTL
+	}
+	value, err := cacheTTL(ttl)
+	if err != nil {
+		return types.CachePointBlock{}, newValidationError(modelName, err.Error())
+	}
+	if value != "" && known && !caps.ExtendedCacheTTL {
+		b.logger().WarnContext(ctx, "bedrock: using the default cache TTL, the model does not support extended cache TTLs", "model", modelName, "ttl", ttl)
+		value = ""
+	}
+	return types.CachePointBlock{Type: cpt, Ttl: value}, nil
+}
+
+// cacheToolsFor reports whether a request caches its tool definitions, from the "cacheTools"
+// config key or the plugin's CacheTools.
+func (b *Bedrock) cacheToolsFor(input *ai.ModelRequest) bool {
+	if configMap, ok := input.Config.(map[string]interface{}); ok {
+		if cacheTools, ok := configMap["cacheTools"].(bool); ok {
+			return cacheTools
+		}
+	}
+	return b.CacheTools
+}
+
+// CachePolicy places cache points automatically for models with prompt caching. A cache point is
+// only placed when the request up to it reaches the model's minimum cacheable tokens, and never
+// beyond the cache points Bedrock accepts per request, counting those placed by hand. Tokens are
+// estimated locally.
+type CachePolicy struct {
+	Tools  bool // Cache the tool definitions
+	System bool // Cache the system prompt
+	Turns  int  // Cache the conversation at the end of each of the last Turns completed turns
+}
+
+// cachePolicyFor returns the cache policy of a model, or nil if cache points aren't placed automatically.
+func (b *Bedrock) cachePolicyFor(modelID string) *CachePolicy {
+	b.limitMu.Lock()
+	defer b.limitMu.Unlock()
+
+	if p, ok := b.cachePolicies[modelID]; ok {
+		return p
+	}
+	return b.CachePolicy
+}
+
+// setModelCachePolicy configures the cache policy of a model defined with its own CachePolicy.
+func (b *Bedrock) setModelCachePolicy(modelID string, policy *CachePolicy) {
+	b.limitMu.Lock()
+	defer b.limitMu.Unlock()
+
+	if b.cachePolicies == nil {
+		b.cachePolicies = make(map[string]*CachePolicy)
+	}
+	b.cachePolicies[modelID] = policy
+}
+
+// placeCachePoints adds the cache points of the model's cache policy to a request. Bedrock caches
+// the request prefix in order: tools, system prompt, then messages. When the policy asks for more
+// cache points than are left, the tools come first, then the system prompt and the most recent
+// turns. The latest turn, which holds the new input, is never cached. It returns the request
+// unchanged when no cache point is added.
+func (b *Bedrock) placeCachePoints(ctx context.Context, modelID string, caps ModelCapabilities, known bool, input *ai.ModelRequest) *ai.ModelRequest {
+	policy := b.cachePolicyFor(modelID)
+	if policy == nil || !known || !caps.PromptCaching {
+		return input
+	}
+	minTokens := caps.MinCacheTokens
+	if minTokens == 0 {
+		minTokens = defaultMinCacheTokens
+	}
+
+	// Cache points placed by hand count towards the limit
+	toolsCached := len(input.Tools) > 0 && b.cacheToolsFor(input)
+	used := 0
+	if toolsCached {
+		used++
+	}
+	for _, msg := range input.Messages {
+		for _, part := range msg.Content {
+			if _, ok := CachePointType(part); ok {
+				used++
+			}
+		}
+	}
+
+	placed := *input
+	placed.Messages = slices.Clone(input.Messages)
+	added := 0
+
+	// Tools are cached through the cacheTools config key, unless the request sets it
+	prefix := estimateInputTokens(&ai.ModelRequest{Tools: input.Tools})
+	if policy.Tools && !toolsCached && len(input.Tools) > 0 && prefix >= minTokens && used < maxCachePoints {
+		configMap, ok := input.Config.(map[string]interface{})
+		if _, set := configMap["cacheTools"]; !set && (ok || input.Config == nil) {
+			config := maps.Clone(configMap)
+			if config == nil {
+				config = make(map[string]interface{})
+			}
+			config["cacheTools"] = true
+			placed.Config = config
+			used++
+			added++
+		}
+	}
+
+	var system, conversation []int
+	for i, msg := range input.Messages {
+		if msg.Role == ai.RoleSystem {
+			system = append(system, i)
+		} else {
+			conversation = append(conversation, i)
+		}
+	}
+
+	for _, i := range system {
+		prefix += estimateInputTokens(&ai.ModelRequest{Messages: input.Messages[i : i+1]})
+	}
+	if policy.System && len(system) > 0 && prefix >= minTokens && used < maxCachePoints {
+		if last := system[len(system)-1]; !endsWithCachePoint(input.Messages[last]) {
+			placed.Messages[last] = withCachePoint(input.Messages[last])
+			used++
+			added++
+		}
+	}
+
+	// Completed turns end before the next user message. Their ends are cached from the most
+	// recent one, as it caches the longest prefix.
+	type turnEnd struct{ index, prefix int }
+	var ends []turnEnd
+	for j, i := range conversation {
+		prefix += estimateInputTokens(&ai.ModelRequest{Messages: input.Messages[i : i+1]})
+		if j+1 < len(conversation) && input.Messages[conversation[j+1]].Role == ai.RoleUser {
+			ends = append(ends, turnEnd{i, prefix})
+		}
+	}
+	for k := len(ends) - 1; k >= 0 && k >= len(ends)-policy.Turns && used < maxCachePoints; k-- {
+		end := ends[k]
+		if end.prefix < minTokens || endsWithCachePoint(input.Messages[end.index]) {
+			continue
+		}
+		placed.Messages[end.index] = withCachePoint(input.Messages[end.index])
+		used++
+		added++
+	}
+
+	if added == 0 {
+		return input
+	}
+	b.logger().DebugContext(ctx, "bedrock: placed cache points", "model", modelID, "added", added, "total", used)
+	return &placed
+}
+
+// endsWithCachePoint reports whether a message ends with a cache point.
+func endsWithCachePoint(msg *ai.Message) bool {
+	if len(msg.Content) == 0 {
+		return false
+	}
+	_, ok := CachePointType(msg.Content[len(msg.Content)-1])
+	return ok
+}
+
+// withCachePoint returns a copy of a message with a cache point appended.
+func withCachePoint(msg *ai.Message) *ai.Message {
+	cached := *msg
+	cached.Content = slices.Concat(msg.Content, []*ai.Part{NewCachePointPart()})
+	return &cached
+}
@@ -0,0 +1,275 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+	"github.com/xavidop/genkit-aws-bedrock-go/bedrocktest"
+)
+
+const extendedCacheModel = "anthropic.claude-sonnet-4-5-20250929-v1:0"
+
+// generateWithCaching sends a request with a system prompt followed by cachePoint and a tool
+// through the plugin and returns the Bedrock input and the response.
+func generateWithCaching(t *testing.T, b *bedrock.Bedrock, modelID string, cachePoint *ai.Part, opts ...ai.GenerateOption) (*types.ToolConfiguration, []types.SystemContentBlock, *ai.ModelResponse) {
+	t.Helper()
+	out := bedrocktest.TextOutput("Done.")
+	out.Usage.CacheReadInputTokens = aws.Int32(1200)
+	out.Usage.CacheWriteInputTokens = aws.Int32(300)
+	fake := bedrocktest.NewClient().AddConverse(out)
+	b.Client = fake
+	g := newTestPlugin(t, b)
+	m := b.DefineModel(g, bedrock.ModelDefinition{Name: modelID, Type: "chat"}, nil)
+	tool := genkit.DefineTool(g, "lookup", "Looks things up", func(ctx *ai.ToolContext, input struct{}) (string, error) {
+		return "", nil
+	})
+
+	opts = append([]ai.GenerateOption{
+		ai.WithModel(m),
+		ai.WithTools(tool),
+		ai.WithMessages(
+			ai.NewSystemMessage(ai.NewTextPart("A long static prompt."), cachePoint),
+			ai.NewUserTextMessage("Hi"),
+		),
+	}, opts...)
+	resp, err := genkit.Generate(context.Background(), g, opts...)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	in := fake.ConverseInputs()[0]
+	return in.ToolConfig, in.System, resp
+}
+
+// toolCachePoint returns the cache point after the tool definitions, if any.
+func toolCachePoint(config *types.ToolConfiguration) *types.CachePointBlock {
+	if config == nil || len(config.Tools) == 0 {
+		return nil
+	}
+	if cachePoint, ok := config.Tools[len(config.Tools)-1].(*types.ToolMemberCachePoint); ok {
+		return &cachePoint.Value
+	}
+	return nil
+}
+
+func TestCacheTools(t *testing.T) {
+	tools, system, resp := generateWithCaching(t, &bedrock.Bedrock{CacheTools: true, CacheTTL: bedrock.ExtendedCacheTTL}, extendedCacheModel, bedrock.NewCachePointPart())
+
+	cachePoint := toolCachePoint(tools)
+	if cachePoint == nil {
+		t.Fatalf("Tools = %#v, want a cache point after the tool definitions", tools.Tools)
+	}
+	if cachePoint.Ttl != types.CacheTTLOneHour {
+		t.Errorf("tool cache point TTL = %q, want %q", cachePoint.Ttl, types.CacheTTLOneHour)
+	}
+	if systemCache, ok := system[1].(*types.SystemContentBlockMemberCachePoint); !ok || systemCache.Value.Ttl != types.CacheTTLOneHour {
+		t.Errorf("system cache point = %#v, want the plugin TTL", system[1])
+	}
+
+	if resp.Usage.CachedContentTokens != 1200 {
+		t.Errorf("CachedContentTokens = %d, want 1200", resp.Usage.CachedContentTokens)
+	}
+	if got := resp.Usage.Custom["cacheWriteInputTokens"]; got != 300 {
+		t.Errorf("Usage.Custom[cacheWriteInputTokens] = %v, want 300", got)
+	}
+}
+
+func TestCacheToolsRequestConfig(t *testing.T) {
+	tools, _, _ := generateWithCaching(t, &bedrock.Bedrock{CacheTools: true}, extendedCacheModel, bedrock.NewCachePointPart(),
+		ai.WithConfig(map[string]any{"cacheTools": false}))
+	if toolCachePoint(tools) != nil {
+		t.Error("tools were cached, want the request config to disable caching")
+	}
+
+	tools, _, _ = generateWithCaching(t, &bedrock.Bedrock{}, extendedCacheModel, bedrock.NewCachePointPart(),
+		ai.WithConfig(map[string]any{"cacheTools": true}))
+	if cachePoint := toolCachePoint(tools); cachePoint == nil || cachePoint.Ttl != "" {
+		t.Errorf("tool cache point = %#v, want one with the default TTL", cachePoint)
+	}
+}
+
+func TestCacheToolsUnsupported(t *testing.T) {
+	// The model doesn't support prompt caching
+	tools, _, _ := generateWithCaching(t, &bedrock.Bedrock{CacheTools: true}, "mistral.mistral-large-2407-v1:0", bedrock.NewCachePointPart())
+	if toolCachePoint(tools) != nil {
+		t.Error("tools were cached for a model without prompt caching")
+	}
+
+	// The model supports prompt caching but not the extended TTL
+	tools, _, _ = generateWithCaching(t, &bedrock.Bedrock{CacheTools: true, CacheTTL: time.Hour}, testModel, bedrock.NewCachePointPart())
+	if cachePoint := toolCachePoint(tools); cachePoint == nil || cachePoint.Ttl != "" {
+		t.Errorf("tool cache point = %#v, want one with the default TTL", cachePoint)
+	}
+}
+
+func TestCachePointTTL(t *testing.T) {
+	_, system, _ := generateWithCaching(t, &bedrock.Bedrock{}, extendedCacheModel, bedrock.NewCachePointPartWithTTL(time.Hour))
+	if systemCache, ok := system[1].(*types.SystemContentBlockMemberCachePoint); !ok || systemCache.Value.Ttl != types.CacheTTLOneHour {
+		t.Errorf("system cache point = %#v, want the part TTL", system[1])
+	}
+
+	fake := bedrocktest.NewClient()
+	g, m := defineTestModel(t, fake, extendedCacheModel)
+	_, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithMessages(ai.NewUserMessage(ai.NewTextPart("Hi"), bedrock.NewCachePointPartWithTTL(10*time.Minute))),
+	)
+	if !errors.Is(err, bedrock.ErrValidation) {
+		t.Errorf("error = %v, want ErrValidation for an unsupported TTL", err)
+	}
+}
+
+// cachePoints lists where the request sent to Bedrock has cache points: "tools", "system" or
+// the index of a message.
+func cachePoints(in *bedrockruntime.ConverseInput) []string {
+	var points []string
+	if toolCachePoint(in.ToolConfig) != nil {
+		points = append(points, "tools")
+	}
+	for _, block := range in.System {
+		if _, ok := block.(*types.SystemContentBlockMemberCachePoint); ok {
+			points = append(points, "system")
+		}
+	}
+	for i, msg := range in.Messages {
+		for _, block := range msg.Content {
+			if _, ok := block.(*types.ContentBlockMemberCachePoint); ok {
+				points = append(points, strconv.Itoa(i))
+			}
+		}
+	}
+	return points
+}
+
+// generateWithPolicy sends a three turn conversation with a system prompt and a tool of the given
+// sizes in characters, and returns where cache points were placed.
+func generateWithPolicy(t *testing.T, modelID string, policy *bedrock.CachePolicy, toolChars, systemChars int, opts ...ai.GenerateOption) []string {
+	t.Helper()
+	fake := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("Done."))
+	b := &bedrock.Bedrock{Client: fake, CachePolicy: policy}
+	g := newTestPlugin(t, b)
+	m := b.DefineModel(g, bedrock.ModelDefinition{Name: modelID, Type: "chat"}, nil)
+	tool := genkit.DefineTool(g, "lookup", strings.Repeat("x", toolChars), func(ctx *ai.ToolContext, input struct{}) (string, error) {
+		return "", nil
+	})
+
+	long := strings.Repeat("A long message. ", 300) // About 1200 tokens
+	opts = append([]ai.GenerateOption{
+		ai.WithModel(m),
+		ai.WithTools(tool),
+		ai.WithMessages(
+			ai.NewSystemTextMessage(strings.Repeat("y", systemChars)),
+			ai.NewUserTextMessage(long),
+			ai.NewModelTextMessage("First answer."),
+			ai.NewUserTextMessage("Second question."),
+			ai.NewModelTextMessage("Second answer."),
+			ai.NewUserTextMessage("Third question."),
+			ai.NewModelTextMessage("Third answer."),
+			ai.NewUserTextMessage("New question."),
+		),
+	}, opts...)
+	if _, err := genkit.Generate(context.Background(), g, opts...); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	return cachePoints(fake.ConverseInputs()[0])
+}
+
+func TestCachePolicy(t *testing.T) {
+	policy := &bedrock.CachePolicy{Tools: true, System: true, Turns: 3}
+
+	// Four cache points at most: tools, system and the two most recent completed turns
+	got := generateWithPolicy(t, extendedCacheModel, policy, 5000, 100)
+	if want := []string{"tools", "system", "3", "5"}; !slices.Equal(got, want) {
+		t.Errorf("cache points = %v, want %v", got, want)
+	}
+
+	// Tools and system prompt below the minimum cacheable tokens
+	got = generateWithPolicy(t, extendedCacheModel, policy, 100, 100)
+	if want := []string{"1", "3", "5"}; !slices.Equal(got, want) {
+		t.Errorf("cache points = %v, want %v", got, want)
+	}
+
+	// The request config keeps control over tool caching
+	got = generateWithPolicy(t, extendedCacheModel, policy, 5000, 100, ai.WithConfig(map[string]any{"cacheTools": false}))
+	if want := []string{"system", "1", "3", "5"}; !slices.Equal(got, want) {
+		t.Errorf("cache points = %v, want %v", got, want)
+	}
+
+	// The model doesn't support prompt caching
+	if got := generateWithPolicy(t, "mistral.mistral-large-2407-v1:0", policy, 5000, 5000); len(got) != 0 {
+		t.Errorf("cache points = %v, want none", got)
+	}
+}
+
+func TestCachePointPartJSON(t *testing.T) {
+	// Histories are often stored as JSON, e.g. in session stores
+	data, err := json.Marshal([]*ai.Message{
+		ai.NewSystemMessage(ai.NewTextPart("A long static prompt."), bedrock.NewCachePointPartWithTTL(time.Hour)),
+		ai.NewUserMessage(ai.NewTextPart("Hi"), bedrock.NewCachePointPart()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []*ai.Message
+	if err := json.Unmarshal(data, &messages); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("Done."))
+	g, m := defineTestModel(t, fake, extendedCacheModel)
+	if _, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithMessages(messages...)); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	in := fake.ConverseInputs()[0]
+	if got, want := cachePoints(in), []string{"system", "0"}; !slices.Equal(got, want) {
+		t.Fatalf("cache points = %v, want %v", got, want)
+	}
+	if systemCache := in.System[1].(*types.SystemContentBlockMemberCachePoint); systemCache.Value.Ttl != types.CacheTTLOneHour {
+		t.Errorf("system cache point TTL = %q, want %q", systemCache.Value.Ttl, types.CacheTTLOneHour)
+	}
+}
+
+func TestCachePointPartUnknownType(t *testing.T) {
+	fake := bedrocktest.NewClient()
+	g, m := defineTestModel(t, fake, extendedCacheModel)
+
+	part := ai.NewCustomPart(map[string]any{"bedrockCachePointType": "forever"})
+	_, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithMessages(ai.NewUserMessage(ai.NewTextPart("Hi"), part)),
+	)
+	if !errors.Is(err, bedrock.ErrValidation) {
+		t.Fatalf("error = %v, want ErrValidation", err)
+	}
+	if n := len(fake.ConverseInputs()); n != 0 {
+		t.Errorf("got %d Converse calls, want the request rejected before calling Bedrock", n)
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core"
+)
+
+// Modalities used in the model catalog.
+const (
+	ModalityText      = "text"
+	ModalityImage     = "image"
+	ModalityVideo     = "video"
+	ModalityEmbedding = "embedding"
+)
+
+// Tool result content types used in the model catalog. Text is supported by every model with tools.
+const (
+	ToolResultJSON     = "json"
+	ToolResultImage    = "image"
+	ToolResultDocument = "document"
+	ToolResultVideo    = "video"
+)
+
+//go:embed catalog/models.json
+var builtinCatalogJSON []byte
+
+// builtinCatalog parses the embedded catalog once.
+var builtinCatalog = sync.OnceValue(func() *ModelCatalog {
+	c, err := ParseCatalog(builtinCatalogJSON)
+	if err != nil {
+		panic(fmt.Sprintf("bedrock: invalid built-in model catalog: %v", err))
+	}
+	return c
+})
+
+// ModelCapabilities describes what a Bedrock foundation model supports.
+type ModelCapabilities struct {
+	InputModalities  []string `json:"inputModalities"`  // e.g. "text", "image", "video"
+	OutputModalities []string `json:"outputModalities"` // e.g. "text", "image", "embedding"
+	Tools            bool     `json:"tools"`            // Tool use via the Converse API
+	StreamingTools   bool     `json:"streamingTools"`   // Tool use via the ConverseStream API
+	ToolResults      []string `json:"toolResults"`      // Tool result content besides text, e.g. "json", "image"
+	ToolResultStatus bool     `json:"toolResultStatus"` // Error status on tool results
+	SystemPrompt     bool     `json:"systemPrompt"`     // System prompts
+	Documents        bool     `json:"documents"`        // Document content blocks
+	Video            bool     `json:"video"`            // Video content blocks
+	Reasoning        bool     `json:"reasoning"`        // Extended thinking / reasoning content
+	Prefill          bool     `json:"prefill"`          // Assistant prefill (a trailing assistant message)
+	PromptCaching    bool     `json:"promptCaching"`    // Cache points
+	ExtendedCacheTTL bool     `json:"extendedCacheTTL"` // One hour cache TTL
+	MinCacheTokens   int      `json:"minCacheTokens"`   // Minimum tokens before a cache point (0 if unknown)
+	ContextWindow    int      `json:"contextWindow"`    // Context window in tokens (0 if unknown)
+	MaxOutputTokens  int      `json:"maxOutputTokens"`  // Maximum output tokens (0 if unknown)
+}
+
+// SupportsInput reports whether the model accepts the given input modality.
+func (c ModelCapabilities) SupportsInput(modality string) bool {
+	return slices.Contains(c.InputModalities, modality)
+}
+
+// SupportsToolResult reports whether the model accepts the given content type in tool results.
+func (c ModelCapabilities) SupportsToolResult(contentType string) bool {
+	return slices.Contains(c.ToolResults, contentType)
+}
+
+// ModelCatalog is a versioned set of model capabilities keyed by foundation model ID.
+type ModelCatalog struct {
+	Version string                       `json:"version"`
+	Models  map[string]ModelCapabilities `json:"models"`
+}
+
+// DefaultCatalog returns a copy of the model catalog built into the plugin.
+func DefaultCatalog() *ModelCatalog {
+	c := builtinCatalog()
+	return &ModelCatalog{
+		Version: c.Version,
+		Models:  maps.Clone(c.Models),
+	}
+}
+
+// ParseCatalog parses a JSON model catalog.
+func ParseCatalog(data []byte) (*ModelCatalog, error) {
+	var c ModelCatalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse model catalog: %w", err)
+	}
+	if c.Models == nil {
+		c.Models = make(map[string]ModelCapabilities)
+	}
+	return &c, nil
+}
+
+// LoadCatalog reads a JSON model catalog from a file.
+func LoadCatalog(path string) (*ModelCatalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model catalog: %w", err)
+	}
+	return ParseCatalog(data)
+}
+
+// Merge adds the models of other to the catalog. An entry for a model ID already in the
+// catalog replaces the whole entry, not individual fields, so overrides must list every
+// capability of the model; start from the DefaultCatalog entry to change a single field.
+func (c *ModelCatalog) Merge(other *ModelCatalog) {
+	if other == nil {
+		return
+	}
+	if other.Version != "" {
+		c.Version = other.Version
+	}
+	if c.Models == nil {
+		c.Models = make(map[string]ModelCapabilities)
+	}
+	maps.Copy(c.Models, other.Models)
+}
+
+// Lookup returns the capabilities of a model. Inference profile IDs and ARNs are looked up
+// by their base model ID (see BaseModelID).
+func (c *ModelCatalog) Lookup(modelID string) (ModelCapabilities, bool) {
+	if caps, ok := c.Models[modelID]; ok {
+		return caps, true
+	}
+	caps, ok := c.Models[BaseModelID(modelID)]
+	return caps, ok
+}
+
+// loadCatalog builds the plugin catalog from the built-in catalog, CatalogFile and CatalogOverrides.
+func (b *Bedrock) loadCatalog() error {
+	catalog := DefaultCatalog()
+	if b.CatalogFile != "" {
+		fileCatalog, err := LoadCatalog(b.CatalogFile)
+		if err != nil {
+			return err
+		}
+		catalog.Merge(fileCatalog)
+	}
+	catalog.Merge(&ModelCatalog{Models: b.CatalogOverrides})
+	b.catalog = catalog
+	return nil
+}
+
+// capabilities returns the catalog entry for a model ID, resolving application inference
+// profiles and provisioned models to their base model.
+func (b *Bedrock) capabilities(ctx context.Context, modelID string) (ModelCapabilities, bool) {
+	catalog := b.catalog
+	if catalog == nil {
+		catalog = builtinCatalog()
+	}
+	if caps, ok := catalog.Lookup(modelID); ok {
+		return caps, true
+	}
+	return catalog.Lookup(b.resolveBaseModelID(ctx, modelID))
+}
+
+// ModelCapabilities returns the catalog entry for a model ID and whether the model is known.
+func (b *Bedrock) ModelCapabilities(ctx context.Context, modelID string) (ModelCapabilities, bool) {
+	return b.capabilities(ctx, modelID)
+}
+
+// validateRequest checks a text generation request against the model capabilities.
+func validateRequest(modelID string, caps ModelCapabilities, input *ai.ModelRequest, streaming bool) error {
+	if len(input.Tools) > 0 {
+		if !caps.Tools {
+			return newValidationError(modelID, "model does not support tool use")
+		}
+		if streaming && !caps.StreamingTools {
+			return newValidationError(modelID, "model does not support tool use while streaming")
+		}
+	}
+
+	for _, msg := range input.Messages {
+		if msg.Role == ai.RoleSystem && !caps.SystemPrompt {
+			return newValidationError(modelID, "model does not support system prompts")
+		}
+		for _, part := range msg.Content {
+			switch {
+			case part.IsMedia():
+				if err := validateMedia(modelID, caps, mediaPartType(part)); err != nil {
+					return err
+				}
+			case part.IsReasoning() && !caps.Reasoning:
+				return newValidationError(modelID, "model does not support reasoning content")
+			}
+		}
+	}
+
+	if caps.MaxOutputTokens > 0 {
+		if configMap, ok := input.Config.(map[string]interface{}); ok {
+			maxTokens, ok := configMap["maxOutputTokens"].(int)
+			if !ok {
+				maxTokens, _ = configMap["max_tokens"].(int)
+			}
+			if maxTokens > caps.MaxOutputTokens {
+				return newValidationError(modelID, fmt.Sprintf("maxOutputTokens %d exceeds the model limit of %d", maxTokens, caps.MaxOutputTokens))
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateMedia checks a media part against the capability matching its MIME type: videos
+// need video support, document types need document support and anything else is sent as an
+// image, which needs image input.
+func validateMedia(modelID string, caps ModelCapabilities, mediaType string) error {
+	switch {
+	case strings.HasPrefix(mediaType, "video/"):
+		if !caps.Video {
+			return newValidationError(modelID, "model does not support video input")
+		}
+	case isDocumentType(mediaType):
+		if !caps.Documents {
+			return newValidationError(modelID, fmt.Sprintf("model does not support document input (%s)", mediaType))
+		}
+	default:
+		// Media of other types is sent as an image
+		if !caps.SupportsInput(ModalityImage) {
+			return newValidationError(modelID, "model does not support image input")
+		}
+	}
+	return nil
+}
+
+// newValidationError reports a request the model cannot serve according to the catalog.
+func newValidationError(modelID, msg string) *Error {
+	return &Error{
+		Kind:      ErrValidation,
+		Status:    core.INVALID_ARGUMENT,
+		Operation: "Converse",
+		ModelID:   modelID,
+		Err:       fmt.Errorf("%s", msg),
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+	"github.com/xavidop/genkit-aws-bedrock-go/bedrocktest"
+)
+
+func TestCatalogLookup(t *testing.T) {
+	catalog := bedrock.DefaultCatalog()
+
+	caps, ok := catalog.Lookup("us." + testModel)
+	if !ok {
+		t.Fatalf("Lookup(us.%s) found nothing, want the base model", testModel)
+	}
+	if !caps.Tools || !caps.SystemPrompt {
+		t.Errorf("capabilities = %+v, want tools and system prompt support", caps)
+	}
+
+	if _, ok := catalog.Lookup("example.unknown-model-v1:0"); ok {
+		t.Error("Lookup of an unknown model succeeded")
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	fake := bedrocktest.NewClient()
+	g, m := defineTestModel(t, fake, "amazon.titan-text-express-v1")
+
+	_, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithPrompt("Write a novel"),
+		ai.WithConfig(map[string]any{"maxOutputTokens": 100000}),
+	)
+	if !errors.Is(err, bedrock.ErrValidation) {
+		t.Fatalf("error = %v, want ErrValidation", err)
+	}
+	if n := len(fake.ConverseInputs()); n != 0 {
+		t.Errorf("got %d Converse calls, want the request rejected before calling Bedrock", n)
+	}
+}
+
+func TestAssistantPrefill(t *testing.T) {
+	fake := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput(`"answer": 42}`))
+	g, m := defineTestModel(t, fake, testModel)
+	tool := genkit.DefineTool(g, "lookup", "Looks things up", func(ctx *ai.ToolContext, input struct{}) (string, error) {
+		return "", nil
+	})
+
+	_, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithTools(tool),
+		ai.WithMessages(ai.NewUserTextMessage("Reply in JSON"), ai.NewModelTextMessage("{ \n")),
+	)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	// The prefill is kept even with tools, without its trailing whitespace
+	messages := fake.ConverseInputs()[0].Messages
+	if len(messages) != 2 || messages[1].Role != types.ConversationRoleAssistant {
+		t.Fatalf("Messages = %#v, want the user message and the prefill", messages)
+	}
+	if text, ok := messages[1].Content[0].(*types.ContentBlockMemberText); !ok || text.Value != "{" {
+		t.Errorf("prefill = %#v, want %q", messages[1].Content[0], "{")
+	}
+}
+
+func TestAssistantPrefillWhitespace(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		prefill *ai.Message
+		want    []string
+	}{
+		{"whitespace only", ai.NewModelTextMessage(" \n"), nil},
+		{"trailing whitespace block", ai.NewModelMessage(ai.NewTextPart("{"), ai.NewTextPart("\n")), []string{"{"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("42"))
+			g, m := defineTestModel(t, fake, testModel)
+
+			_, err := genkit.Generate(context.Background(), g,
+				ai.WithModel(m),
+				ai.WithMessages(ai.NewUserTextMessage("Reply in JSON"), tc.prefill),
+			)
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+
+			// Empty text blocks are dropped, and so is a prefill left without content
+			messages := fake.ConverseInputs()[0].Messages
+			if tc.want == nil {
+				if len(messages) != 1 || messages[0].Role != types.ConversationRoleUser {
+					t.Fatalf("Messages = %#v, want only the user message", messages)
+				}
+				return
+			}
+			if len(messages) != 2 {
+				t.Fatalf("got %d messages, want the user message and the prefill", len(messages))
+			}
+			var got []string
+			for _, block := range messages[1].Content {
+				text, ok := block.(*types.ContentBlockMemberText)
+				if !ok {
+					t.Fatalf("prefill block = %#v, want text", block)
+				}
+				got = append(got, text.Value)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("prefill = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAssistantPrefillUnsupported(t *testing.T) {
+	fake := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("42"))
+	g, m := defineTestModel(t, fake, "amazon.titan-text-express-v1")
+
+	resp, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithMessages(ai.NewUserTextMessage("Reply in JSON"), ai.NewModelTextMessage("{")),
+	)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	if messages := fake.ConverseInputs()[0].Messages; len(messages) != 1 {
+		t.Errorf("got %d messages, want the prefill dropped", len(messages))
+	}
+	if got := resp.Custom.(map[string]any)["droppedPrefill"]; got != true {
+		t.Errorf("Custom[droppedPrefill] = %v, want true", got)
+	}
+}
+
+func TestAssistantPrefillUnsupportedKeepsToolRequests(t *testing.T) {
+	fake := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("42"))
+	_, m := defineTestModel(t, fake, "meta.llama3-1-70b-instruct-v1:0")
+
+	// A trailing tool request is not a prefill, it is answered with a placeholder result
+	resp, err := m.Generate(context.Background(), &ai.ModelRequest{
+		Messages: []*ai.Message{
+			ai.NewUserTextMessage("Look it up"),
+			ai.NewModelMessage(ai.NewToolRequestPart(&ai.ToolRequest{Name: "lookup", Ref: "tool-1", Input: map[string]any{}})),
+		},
+		Tools: []*ai.ToolDefinition{{Name: "lookup", Description: "Looks things up", InputSchema: map[string]any{"type": "object"}}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	messages := fake.ConverseInputs()[0].Messages
+	if len(messages) != 3 {
+		t.Fatalf("got %d messages, want the tool request and a placeholder result", len(messages))
+	}
+	if _, ok := messages[1].Content[0].(*types.ContentBlockMemberToolUse); !ok {
+		t.Errorf("messages[1] = %#v, want the tool request", messages[1].Content[0])
+	}
+	if _, ok := messages[2].Content[0].(*types.ContentBlockMemberToolResult); !ok {
+		t.Errorf("messages[2] = %#v, want a placeholder tool result", messages[2].Content[0])
+	}
+	if custom, ok := resp.Custom.(map[string]any); ok && custom["droppedPrefill"] != nil {
+		t.Errorf("Custom[droppedPrefill] = %v, want unset", custom["droppedPrefill"])
+	}
+}
+
+func TestValidateMedia(t *testing.T) {
+	media := func(contentType string) *ai.Message {
+		return ai.NewUserMessage(ai.NewTextPart("Describe this."), ai.NewMediaPart(contentType, "data:"+contentType+";base64,AAAA"))
+	}
+	for _, tc := range []struct {
+		name    string
+		model   string
+		message *ai.Message
+		wantErr bool
+	}{
+		{"image", "anthropic.claude-3-haiku-20240307-v1:0", media("image/png"), false},
+		{"image without image input", testModel, media("image/png"), true},
+		{"video", "amazon.nova-lite-v1:0", media("video/mp4"), false},
+		{"video without video support", "anthropic.claude-3-haiku-20240307-v1:0", media("video/mp4"), true},
+		{"document", testModel, media("application/pdf"), false},
+		{"document without document support", "meta.llama3-2-11b-instruct-v1:0", media("application/pdf"), true},
+		{"other type as image", "meta.llama3-2-11b-instruct-v1:0", media("application/octet-stream"), false},
+		{"other type without image input", testModel, media("application/octet-stream"), true},
+		{"reasoning without reasoning support", testModel,
+			ai.NewModelMessage(ai.NewReasoningPart("Thinking...", nil), ai.NewTextPart("Hi")), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("ok"))
+			g, m := defineTestModel(t, fake, tc.model)
+
+			_, err := genkit.Generate(context.Background(), g,
+				ai.WithModel(m),
+				ai.WithMessages(tc.message, ai.NewUserTextMessage("Go on.")),
+			)
+			if tc.wantErr && !errors.Is(err, bedrock.ErrValidation) {
+				t.Errorf("error = %v, want ErrValidation", err)
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("Generate: %v", err)
+			}
+		})
+	}
+}
+
+func TestMediaBlocks(t *testing.T) {
+	fake := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("ok"))
+	g, m := defineTestModel(t, fake, "amazon.nova-lite-v1:0")
+
+	_, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithMessages(ai.NewUserMessage(
+			ai.NewMediaPart("image/jpeg", "data:image/jpeg;base64,AAAA"),
+			ai.NewMediaPart("video/mp4", "data:video/mp4;base64,AAAA"),
+			ai.NewMediaPart("application/pdf", "data:application/pdf;base64,AAAA"),
+			ai.NewMediaPart("application/octet-stream", "data:application/octet-stream;base64,AAAA"),
+		)),
+	)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	content := fake.ConverseInputs()[0].Messages[0].Content
+	if len(content) != 4 {
+		t.Fatalf("got %d content blocks, want 4", len(content))
+	}
+	if image, ok := content[0].(*types.ContentBlockMemberImage); !ok || image.Value.Format != types.ImageFormatJpeg {
+		t.Errorf("content[0] = %#v, want a JPEG image", content[0])
+	}
+	if video, ok := content[1].(*types.ContentBlockMemberVideo); !ok || video.Value.Format != types.VideoFormatMp4 {
+		t.Errorf("content[1] = %#v, want an MP4 video", content[1])
+	}
+	if doc, ok := content[2].(*types.ContentBlockMemberDocument); !ok || doc.Value.Format != types.DocumentFormatPdf {
+		t.Errorf("content[2] = %#v, want a PDF document", content[2])
+	}
+	// Other types are sent as images, as they were before documents were supported
+	if _, ok := content[3].(*types.ContentBlockMemberImage); !ok {
+		t.Errorf("content[3] = %#v, want an image", content[3])
+	}
+}
+
+func TestCatalogMergeReplacesEntries(t *testing.T) {
+	catalog := bedrock.DefaultCatalog()
+	catalog.Merge(&bedrock.ModelCatalog{Models: map[string]bedrock.ModelCapabilities{
+		testModel: {ContextWindow: 100000},
+	}})
+
+	caps, _ := catalog.Lookup(testModel)
+	if caps.ContextWindow != 100000 || caps.Tools {
+		t.Errorf("merged capabilities = %+v, want the override entry only", caps)
+	}
+}
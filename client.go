@@ -0,0 +1,208 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	awsbedrock "github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	smithyauth "github.com/aws/smithy-go/auth"
+	"github.com/aws/smithy-go/logging"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// BedrockClient is the subset of the Bedrock Runtime API used by the plugin.
+// NewBedrockClient adapts a *bedrockruntime.Client; the bedrocktest package provides
+// a programmable fake for tests.
+type BedrockClient interface {
+	Converse(ctx context.Context, input *bedrockruntime.ConverseInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error)
+	ConverseStream(ctx context.Context, input *bedrockruntime.ConverseStreamInput, optFns ...func(*bedrockruntime.Options)) (*ConverseStream, error)
+	InvokeModel(ctx context.Context, input *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error)
+	CountTokens(ctx context.Context, input *bedrockruntime.CountTokensInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.CountTokensOutput, error)
+}
+
+// ConverseStream is an open ConverseStream response.
+type ConverseStream struct {
+	// Reader delivers the stream events. The plugin closes it once the stream is consumed.
+	Reader bedrockruntime.ConverseStreamOutputReader
+
+	// ResultMetadata holds the operation metadata, such as the AWS request ID.
+	ResultMetadata middleware.Metadata
+}
+
+// NewBedrockClient adapts a Bedrock Runtime client to BedrockClient.
+func NewBedrockClient(client *bedrockruntime.Client) BedrockClient {
+	return runtimeClient{client}
+}
+
+// runtimeClient implements BedrockClient with the AWS SDK client.
+type runtimeClient struct {
+	*bedrockruntime.Client
+}
+
+// ConverseStream starts a ConverseStream call.
+func (c runtimeClient) ConverseStream(ctx context.Context, input *bedrockruntime.ConverseStreamInput, optFns ...func(*bedrockruntime.Options)) (*ConverseStream, error) {
+	out, err := c.Client.ConverseStream(ctx, input, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	return &ConverseStream{Reader: out.GetStream(), ResultMetadata: out.ResultMetadata}, nil
+}
+
+// StaticCredentials are fixed AWS credentials.
+type StaticCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // Optional, for temporary credentials
+}
+
+// applyClientConfig applies the plugin's HTTP, credential and logging settings to the AWS config.
+func (b *Bedrock) applyClientConfig(cfg *aws.Config) error {
+	// Log the SDK's retries with the plugin logger, unless a custom AWS config brings its own
+	// logging and no plugin logger is set
+	if b.AWSConfig == nil || b.Logger != nil {
+		cfg.Logger = sdkLogger{logger: b.logger()}
+		cfg.ClientLogMode |= aws.LogRetries
+	}
+
+	switch {
+	case b.HTTPClient != nil && b.ProxyURL != "":
+		return errors.New("ProxyURL cannot be combined with HTTPClient, configure the proxy on the HTTP client instead")
+	case b.HTTPClient != nil:
+		cfg.HTTPClient = b.HTTPClient
+	case b.ProxyURL != "":
+		proxyURL, err := url.Parse(b.ProxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid ProxyURL: %w", err)
+		}
+		cfg.HTTPClient = awshttp.NewBuildableClient().WithTransportOptions(func(t *http.Transport) {
+			t.Proxy = http.ProxyURL(proxyURL)
+		})
+	}
+
+	switch {
+	case b.APIKey != "" && (b.StaticCredentials != nil || b.Anonymous):
+		return errors.New("APIKey cannot be combined with StaticCredentials or Anonymous")
+	case b.StaticCredentials != nil && b.Anonymous:
+		return errors.New("StaticCredentials cannot be combined with Anonymous")
+	case b.StaticCredentials != nil:
+		c := b.StaticCredentials
+		cfg.Credentials = credentials.NewStaticCredentialsProvider(c.AccessKeyID, c.SecretAccessKey, c.SessionToken)
+	case b.Anonymous:
+		cfg.Credentials = aws.AnonymousCredentials{}
+	}
+
+	return nil
+}
+
+// sdkLogger adapts the plugin logger to the AWS SDK logger. The SDK logs retries at debug
+// level, which are reported at info level since they add latency to the call.
+type sdkLogger struct {
+	logger *slog.Logger
+	ctx    context.Context
+}
+
+// Logf implements logging.Logger.
+func (l sdkLogger) Logf(classification logging.Classification, format string, v ...interface{}) {
+	level := slog.LevelInfo
+	if classification == logging.Warn {
+		level = slog.LevelWarn
+	}
+	ctx := l.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	l.logger.Log(ctx, level, "bedrock: "+fmt.Sprintf(format, v...))
+}
+
+// WithContext implements logging.ContextLogger.
+func (l sdkLogger) WithContext(ctx context.Context) logging.Logger {
+	return sdkLogger{logger: l.logger, ctx: ctx}
+}
+
+// runtimeOptions returns the Bedrock Runtime client options for the endpoint and auth settings.
+func (b *Bedrock) runtimeOptions(baseEndpoint string) []func(*bedrockruntime.Options) {
+	return []func(*bedrockruntime.Options){func(o *bedrockruntime.Options) {
+		if baseEndpoint != "" {
+			o.BaseEndpoint = aws.String(baseEndpoint)
+		}
+		if b.UseFIPS {
+			o.EndpointOptions.UseFIPSEndpoint = aws.FIPSEndpointStateEnabled
+		}
+		if b.UseDualStack {
+			o.EndpointOptions.UseDualStackEndpoint = aws.DualStackEndpointStateEnabled
+		}
+		if token := b.bearerToken(); token != nil {
+			o.BearerAuthTokenProvider = token
+			o.AuthSchemePreference = []string{bearerAuthScheme}
+		} else if b.StaticCredentials != nil {
+			// Sign with the configured credentials even if AWS_BEARER_TOKEN_BEDROCK is set
+			o.AuthSchemePreference = nil
+		}
+		if b.Anonymous {
+			o.AuthSchemeResolver = anonymousRuntimeAuth{}
+		}
+	}}
+}
+
+// controlOptions returns the Bedrock control plane client options for the endpoint and auth settings.
+// BaseEndpoint only applies to the runtime API.
+func (b *Bedrock) controlOptions() []func(*awsbedrock.Options) {
+	return []func(*awsbedrock.Options){func(o *awsbedrock.Options) {
+		if b.UseFIPS {
+			o.EndpointOptions.UseFIPSEndpoint = aws.FIPSEndpointStateEnabled
+		}
+		if b.UseDualStack {
+			o.EndpointOptions.UseDualStackEndpoint = aws.DualStackEndpointStateEnabled
+		}
+		if token := b.bearerToken(); token != nil {
+			o.BearerAuthTokenProvider = token
+			o.AuthSchemePreference = []string{bearerAuthScheme}
+		} else if b.StaticCredentials != nil {
+			// Sign with the configured credentials even if AWS_BEARER_TOKEN_BEDROCK is set
+			o.AuthSchemePreference = nil
+		}
+		if b.Anonymous {
+			o.AuthSchemeResolver = anonymousControlAuth{}
+		}
+	}}
+}
+
+// Bedrock clients fall back to bearer token auth when credentials are anonymous, so anonymous
+// requests need an auth scheme resolver that selects no auth.
+var anonymousAuth = []*smithyauth.Option{{SchemeID: smithyauth.SchemeIDAnonymous}}
+
+type anonymousRuntimeAuth struct{}
+
+func (anonymousRuntimeAuth) ResolveAuthSchemes(context.Context, *bedrockruntime.AuthResolverParameters) ([]*smithyauth.Option, error) {
+	return anonymousAuth, nil
+}
+
+type anonymousControlAuth struct{}
+
+func (anonymousControlAuth) ResolveAuthSchemes(context.Context, *awsbedrock.AuthResolverParameters) ([]*smithyauth.Option, error) {
+	return anonymousAuth, nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/logging"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+)
+
+func TestBaseEndpointAnonymous(t *testing.T) {
+	var authorization []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = append(authorization, r.Header.Get("Authorization"))
+		if !strings.HasSuffix(r.URL.Path, "/converse") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"output":{"message":{"role":"assistant","content":[{"text":"local"}]}},` +
+			`"stopReason":"end_turn","usage":{"inputTokens":1,"outputTokens":1,"totalTokens":2}}`))
+	}))
+	defer server.Close()
+
+	b := &bedrock.Bedrock{BaseEndpoint: server.URL, Anonymous: true}
+	g := newTestPlugin(t, b)
+	m := b.DefineModel(g, bedrock.ModelDefinition{Name: testModel, Type: "chat"}, nil)
+
+	resp, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("hi"))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if got := resp.Text(); got != "local" {
+		t.Errorf("Text() = %q, want the local endpoint response", got)
+	}
+	if len(authorization) != 1 || authorization[0] != "" {
+		t.Errorf("Authorization headers = %q, want one unsigned request", authorization)
+	}
+}
+
+func TestConflictingClientConfig(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Init succeeded with both StaticCredentials and Anonymous")
+		}
+	}()
+	newTestPlugin(t, &bedrock.Bedrock{
+		StaticCredentials: &bedrock.StaticCredentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"},
+		Anonymous:         true,
+	})
+}
+
+func TestRetriesLogged(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		pluginLogger  bool // Set Bedrock.Logger
+		awsLogger     bool // Set a logger and log mode on AWSConfig
+		wantPluginLog bool
+	}{
+		{name: "plugin logger", pluginLogger: true, wantPluginLog: true},
+		{name: "AWS config logger kept", awsLogger: true},
+		{name: "plugin logger overrides AWS config", pluginLogger: true, awsLogger: true, wantPluginLog: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header().Set("Content-Type", "application/json")
+				if calls == 1 {
+					w.Header().Set("X-Amzn-Errortype", "ServiceUnavailableException")
+					w.WriteHeader(http.StatusServiceUnavailable)
+					w.Write([]byte(`{"message":"try again"}`))
+					return
+				}
+				w.Write([]byte(`{"output":{"message":{"role":"assistant","content":[{"text":"ok"}]}},` +
+					`"stopReason":"end_turn","usage":{"inputTokens":1,"outputTokens":1,"totalTokens":2}}`))
+			}))
+			defer server.Close()
+
+			var pluginLogs, awsLogs bytes.Buffer
+			b := &bedrock.Bedrock{
+				BaseEndpoint: server.URL,
+				Anonymous:    true,
+				AWSConfig: &aws.Config{
+					Region: "us-east-1",
+					Retryer: func() aws.Retryer {
+						return retry.NewStandard(func(o *retry.StandardOptions) {
+							o.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) { return 0, nil })
+						})
+					},
+				},
+			}
+			if tc.pluginLogger {
+				b.Logger = slog.New(slog.NewTextHandler(&pluginLogs, nil))
+			}
+			if tc.awsLogger {
+				b.AWSConfig.Logger = logging.NewStandardLogger(&awsLogs)
+				b.AWSConfig.ClientLogMode = aws.LogRetries
+			}
+			// Init directly, the test helper always sets a plugin logger
+			g := genkit.Init(context.Background(), genkit.WithPlugins(b))
+			m := b.DefineModel(g, bedrock.ModelDefinition{Name: testModel, Type: "chat"}, nil)
+
+			if _, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("hi")); err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			if calls != 2 {
+				t.Fatalf("got %d calls, want a retry", calls)
+			}
+			if got := strings.Contains(pluginLogs.String(), "retrying request"); got != tc.wantPluginLog {
+				t.Errorf("retry in plugin logs = %v, want %v, logs:\n%s", got, tc.wantPluginLog, pluginLogs.String())
+			}
+			wantAWSLog := tc.awsLogger && !tc.wantPluginLog
+			if got := strings.Contains(awsLogs.String(), "retrying request"); got != wantAWSLog {
+				t.Errorf("retry in AWS config logs = %v, want %v, logs:\n%s", got, wantAWSLog, awsLogs.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// Context window strategies.
+const (
+	ContextStrategyTruncate  = "truncate"  // Drop the oldest turns
+	ContextStrategySummarize = "summarize" // Replace the oldest turns with a summary written by the model
+)
+
+// ContextWindow configures automatic history truncation for a text model, so long
+// conversations keep fitting the model context window. Tokens are estimated locally.
+type ContextWindow struct {
+	Strategy         string // ContextStrategyTruncate (default) or ContextStrategySummarize
+	MaxInputTokens   int    // Input token budget (default: catalog context window minus the output tokens)
+	SummaryMaxTokens int    // Maximum length of the summary for ContextStrategySummarize (default: 1024)
+	SummaryPrompt    string // Instruction used to summarize the dropped turns (optional)
+}
+
+// ContextWindowReport describes how a request was trimmed to fit the context window. It is
+// reported in response.Custom["contextWindow"] when older turns were dropped.
+type ContextWindowReport struct {
+	Strategy        string `json:"strategy"`
+	Budget          int    `json:"budget"`          // Input token budget
+	EstimatedTokens int    `json:"estimatedTokens"` // Estimated input tokens after trimming
+	DroppedTurns    int    `json:"droppedTurns"`    // Turns removed from the start of the conversation
+	DroppedMessages int    `json:"droppedMessages"` // Messages in the removed turns
+	Summary         string `json:"summary,omitempty"`
+}
+
+const (
+	defaultSummaryMaxTokens = 1024
+	defaultSummaryPrompt    = "Summarize the conversation so far in a few paragraphs, keeping the facts, " +
+		"decisions and open questions needed to continue it. Reply with the summary only."
+)
+
+// contextWindowFor returns the context window settings of a model, or nil if history is not trimmed.
+func (b *Bedrock) contextWindowFor(modelID string) *ContextWindow {
+	b.limitMu.Lock()
+	defer b.limitMu.Unlock()
+
+	if w, ok := b.contextWindows[modelID]; ok {
+		return w
+	}
+	return b.ContextWindow
+}
+
+// setModelContextWindow configures the context window of a model defined with its own ContextWindow.
+func (b *Bedrock) setModelContextWindow(modelID string, window *ContextWindow) {
+	b.limitMu.Lock()
+	defer b.limitMu.Unlock()
+
+	if b.contextWindows == nil {
+		b.contextWindows = make(map[string]*ContextWindow)
+	}
+	b.contextWindows[modelID] = window
+}
+
+// contextBudget returns the input token budget of a model, or 0 if it is unknown.
+func (b *Bedrock) contextBudget(ctx context.Context, modelID string, window *ContextWindow, input *ai.ModelRequest) int {
+	if window.MaxInputTokens > 0 {
+		return window.MaxInputTokens
+	}
+	caps, ok := b.capabilities(ctx, modelID)
+	if !ok || caps.ContextWindow == 0 {
+		return 0
+	}
+
+	// Leave room for the requested output, or the model maximum when none is requested
+	outputTokens := caps.MaxOutputTokens
+	if configMap, ok := input.Config.(map[string]interface{}); ok {
+		if maxTokens, ok := configMap["maxOutputTokens"].(int); ok {
+			outputTokens = maxTokens
+		} else if maxTokens, ok := configMap["max_tokens"].(int); ok {
+			outputTokens = maxTokens
+		}
+	}
+	return max(caps.ContextWindow-outputTokens, 0)
+}
+
+// splitTurns splits a conversation into turns, each starting with a user message and holding
+// the model replies, tool requests and tool responses that follow it. Tool requests are never
+// separated from their responses. Messages before the first user message form the first turn.
+func splitTurns(messages []*ai.Message) [][]*ai.Message {
+	var turns [][]*ai.Message
+	for _, msg := range messages {
+		if msg.Role == ai.RoleUser || len(turns) == 0 {
+			turns = append(turns, nil)
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], msg)
+	}
+	return turns
+}
+
+// fitContextWindow drops, or summarizes, the oldest turns of the conversation until its
+// estimated size fits the model context window. System messages and the latest turn are always
+// kept, and a cache point in the dropped turns is moved to the first kept message. It returns
+// the request unchanged, and a nil report, when nothing was dropped.
+func (b *Bedrock) fitContextWindow(ctx context.Context, modelID string, input *ai.ModelRequest) (*ai.ModelRequest, *ContextWindowReport, error) {
+	window := b.contextWindowFor(modelID)
+	if window == nil {
+		return input, nil, nil
+	}
+	budget := b.contextBudget(ctx, modelID, window, input)
+	if budget == 0 {
+		return input, nil, nil
+	}
+
+	var system, conversation []*ai.Message
+	for _, msg := range input.Messages {
+		if msg.Role == ai.RoleSystem {
+			system = append(system, msg)
+		} else {
+			conversation = append(conversation, msg)
+		}
+	}
+
+	total := estimateInputTokens(input)
+	if total <= budget {
+		return input, nil, nil
+	}
+
+	summarize := window.Strategy == ContextStrategySummarize
+	summaryTokens := 0
+	if summarize {
+		summaryTokens = window.SummaryMaxTokens
+		if summaryTokens <= 0 {
+			summaryTokens = defaultSummaryMaxTokens
+		}
+	}
+
+	// Drop the oldest turns until the rest fits, keeping at least the latest one
+	turns := splitTurns(conversation)
+	dropped := 0
+	for dropped < len(turns)-1 && total+summaryTokens > budget {
+		total -= estimateInputTokens(&ai.ModelRequest{Messages: turns[dropped]})
+		dropped++
+	}
+	if dropped == 0 {
+		b.logger().WarnContext(ctx, "bedrock: request exceeds the context window but has no older turns to drop",
+			"model", modelID, "estimated_tokens", total, "budget", budget)
+		return input, nil, nil
+	}
+
+	droppedMessages := slices.Concat(turns[:dropped]...)
+	kept := slices.Concat(turns[dropped:]...)
+	report := &ContextWindowReport{
+		Strategy:        ContextStrategyTruncate,
+		Budget:          budget,
+		EstimatedTokens: total,
+		DroppedTurns:    dropped,
+		DroppedMessages: len(droppedMessages),
+	}
+
+	// The first kept message is rebuilt, so the caller's request isn't modified
+	first := &ai.Message{Role: kept[0].Role, Metadata: kept[0].Metadata}
+	if summarize {
+		summary, err := b.summarizeTurns(ctx, modelID, window, input, system, droppedMessages)
+		if err != nil {
+			return nil, nil, err
+		}
+		report.Strategy = ContextStrategySummarize
+		report.Summary = summary
+		report.EstimatedTokens += estimateInputTokens(&ai.ModelRequest{Messages: []*ai.Message{ai.NewUserTextMessage(summary)}})
+		first.Content = append(first.Content, ai.NewTextPart("Summary of the earlier conversation:\n"+summary))
+	}
+
+	// Keep a cached prefix: a cache point in the dropped turns now follows the summary, or the
+	// first kept message when there is no summary
+	hadCachePoint := slices.ContainsFunc(droppedMessages, hasCachePoint)
+	if hadCachePoint && summarize {
+		first.Content = append(first.Content, NewCachePointPart())
+	}
+	first.Content = append(first.Content, kept[0].Content...)
+	if hadCachePoint && !summarize && !hasCachePoint(kept[0]) {
+		first.Content = append(first.Content, NewCachePointPart())
+	}
+	kept[0] = first
+
+	b.logger().InfoContext(ctx, "bedrock: dropped older turns to fit the context window",
+		"model", modelID, "strategy", report.Strategy, "dropped_turns", dropped,
+		"estimated_tokens", report.EstimatedTokens, "budget", budget)
+
+	trimmed := *input
+	trimmed.Messages = slices.Concat(system, kept)
+	return &trimmed, report, nil
+}
+
+// summarizeTurns asks the model for a summary of the dropped turns.
+func (b *Bedrock) summarizeTurns(ctx context.Context, modelID string, window *ContextWindow, input *ai.ModelRequest, system, dropped []*ai.Message) (string, error) {
+	prompt := window.SummaryPrompt
+	if prompt == "" {
+		prompt = defaultSummaryPrompt
+	}
+	maxTokens := window.SummaryMaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultSummaryMaxTokens
+	}
+
+	// Ask in a new user message, or in the last one if the dropped turns end with the user
+	messages := slices.Concat(system, dropped)
+	if last := messages[len(messages)-1]; last.Role == ai.RoleUser {
+		messages[len(messages)-1] = &ai.Message{Role: ai.RoleUser, Content: slices.Concat(last.Content, []*ai.Part{ai.NewTextPart(prompt)})}
+	} else {
+		messages = append(messages, ai.NewUserTextMessage(prompt))
+	}
+
+	// Tools are declared when the dropped turns contain tool calls, as Bedrock requires
+	req := &ai.ModelRequest{
+		Messages: messages,
+		Tools:    input.Tools,
+		Config:   map[string]interface{}{"maxOutputTokens": maxTokens},
+	}
+	converseInput, err := b.buildConverseInput(ctx, modelID, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to build summary input: %w", err)
+	}
+	resp, err := b.limitedConverse(ctx, modelID, converseInput, req, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize the conversation: %w", err)
+	}
+	return strings.TrimSpace(resp.Text()), nil
+}
+
+// hasCachePoint reports whether a message contains a cache point part.
+func hasCachePoint(msg *ai.Message) bool {
+	return slices.ContainsFunc(msg.Content, func(part *ai.Part) bool {
+		_, ok := CachePointType(part)
+		return ok
+	})
+}
@@ -0,0 +1,138 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+	"github.com/xavidop/genkit-aws-bedrock-go/bedrocktest"
+)
+
+// longHistory returns a conversation of three turns of about 25 tokens each, the second one
+// with a tool call, followed by a short question.
+func longHistory() []*ai.Message {
+	filler := strings.Repeat("x", 100)
+	return []*ai.Message{
+		ai.NewSystemTextMessage("Be brief."),
+		ai.NewUserMessage(ai.NewTextPart("first "+filler), bedrock.NewCachePointPart()),
+		ai.NewModelTextMessage("first answer"),
+		ai.NewUserTextMessage("second " + filler),
+		ai.NewModelMessage(ai.NewToolRequestPart(&ai.ToolRequest{Name: "lookup", Ref: "tool-1", Input: map[string]any{"q": "x"}})),
+		ai.NewMessage(ai.RoleTool, nil, ai.NewToolResponsePart(&ai.ToolResponse{Name: "lookup", Ref: "tool-1", Output: "found"})),
+		ai.NewModelTextMessage("second answer"),
+		ai.NewUserTextMessage("third " + filler),
+		ai.NewModelTextMessage("third answer"),
+		ai.NewUserTextMessage("And now?"),
+	}
+}
+
+func TestContextWindowTruncate(t *testing.T) {
+	fake := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("ok"))
+	b := &bedrock.Bedrock{Client: fake, ContextWindow: &bedrock.ContextWindow{MaxInputTokens: 40}}
+	g := newTestPlugin(t, b)
+	m := b.DefineModel(g, bedrock.ModelDefinition{Name: testModel, Type: "chat"}, nil)
+
+	resp, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithMessages(longHistory()...))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	in := fake.ConverseInputs()[0]
+	if len(in.System) != 1 {
+		t.Errorf("got %d system blocks, want the system prompt kept", len(in.System))
+	}
+	// The first two turns, including the tool call and its result, are dropped together
+	if len(in.Messages) != 3 {
+		t.Fatalf("got %d messages, want the last 3", len(in.Messages))
+	}
+	first := in.Messages[0].Content
+	if text, ok := first[0].(*types.ContentBlockMemberText); !ok || !strings.HasPrefix(text.Value, "third") {
+		t.Errorf("first message starts with %#v, want the third turn", first[0])
+	}
+	if _, ok := first[len(first)-1].(*types.ContentBlockMemberCachePoint); !ok {
+		t.Errorf("first message ends with %#v, want the dropped cache point", first[len(first)-1])
+	}
+
+	report, ok := resp.Custom.(map[string]any)["contextWindow"].(*bedrock.ContextWindowReport)
+	if !ok {
+		t.Fatalf("Custom[contextWindow] = %v, want a report", resp.Custom)
+	}
+	if report.DroppedTurns != 2 || report.DroppedMessages != 6 || report.Budget != 40 {
+		t.Errorf("report = %+v, want 2 turns and 6 messages dropped within 40 tokens", report)
+	}
+}
+
+func TestContextWindowSummarize(t *testing.T) {
+	fake := bedrocktest.NewClient().
+		AddConverse(bedrocktest.TextOutput("The user asked three questions.")).
+		AddConverse(bedrocktest.TextOutput("ok"))
+	b := &bedrock.Bedrock{Client: fake}
+	g := newTestPlugin(t, b)
+	m := b.DefineModel(g, bedrock.ModelDefinition{
+		Name:          testModel,
+		Type:          "chat",
+		ContextWindow: &bedrock.ContextWindow{Strategy: bedrock.ContextStrategySummarize, MaxInputTokens: 100, SummaryMaxTokens: 20},
+	}, nil)
+	tool := genkit.DefineTool(g, "lookup", "Looks things up", func(ctx *ai.ToolContext, input struct {
+		Q string `json:"q"`
+	}) (string, error) {
+		return "found", nil
+	})
+
+	resp, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithMessages(longHistory()...),
+		ai.WithTools(tool),
+	)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	inputs := fake.ConverseInputs()
+	if len(inputs) != 2 {
+		t.Fatalf("got %d Converse calls, want a summary and the request", len(inputs))
+	}
+	summaryIn := inputs[0]
+	if summaryIn.ToolConfig == nil || summaryIn.InferenceConfig == nil || aws.ToInt32(summaryIn.InferenceConfig.MaxTokens) != 20 {
+		t.Errorf("summary request has ToolConfig %v and InferenceConfig %#v, want the tools and 20 max tokens",
+			summaryIn.ToolConfig, summaryIn.InferenceConfig)
+	}
+
+	in := inputs[1]
+	if len(in.Messages) != 3 {
+		t.Fatalf("got %d messages, want the last 3", len(in.Messages))
+	}
+	first := in.Messages[0].Content
+	if text, ok := first[0].(*types.ContentBlockMemberText); !ok || !strings.Contains(text.Value, "The user asked three questions.") {
+		t.Errorf("first message starts with %#v, want the summary", first[0])
+	}
+	if _, ok := first[1].(*types.ContentBlockMemberCachePoint); !ok {
+		t.Errorf("second block is %#v, want a cache point after the summary", first[1])
+	}
+
+	report := resp.Custom.(map[string]any)["contextWindow"].(*bedrock.ContextWindowReport)
+	if report.Strategy != bedrock.ContextStrategySummarize || report.Summary != "The user asked three questions." {
+		t.Errorf("report = %+v, want the summary", report)
+	}
+}
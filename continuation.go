@@ -0,0 +1,248 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/firebase/genkit/go/ai"
+)
+
+// maxContinuationsFor returns how many times a model's output is continued after hitting the
+// maximum output tokens.
+func (b *Bedrock) maxContinuationsFor(modelID string) int {
+	b.limitMu.Lock()
+	defer b.limitMu.Unlock()
+
+	if n, ok := b.continuations[modelID]; ok {
+		return n
+	}
+	return b.MaxContinuations
+}
+
+// setModelMaxContinuations configures the continuations of a model defined with its own MaxContinuations.
+func (b *Bedrock) setModelMaxContinuations(modelID string, n int) {
+	b.limitMu.Lock()
+	defer b.limitMu.Unlock()
+
+	if b.continuations == nil {
+		b.continuations = make(map[string]int)
+	}
+	b.continuations[modelID] = n
+}
+
+// converseWithContinuation calls the model and, while the output stops at the maximum output
+// tokens, calls it again with the output so far as an assistant prefill, up to the model's
+// MaxContinuations. The outputs are stitched into one response, also when streaming, and the
+// usage is summed across calls.
+func (b *Bedrock) converseWithContinuation(ctx context.Context, modelName string, converseInput *bedrockruntime.ConverseInput, input *ai.ModelRequest, cb func(context.Context, *ai.ModelResponseChunk) error) (*ai.ModelResponse, error) {
+	maxContinuations := b.maxContinuationsFor(modelName)
+	if maxContinuations <= 0 {
+		return b.limitedConverse(ctx, modelName, converseInput, input, cb)
+	}
+
+	// Streamed text holds back its trailing whitespace, which is dropped if the output is
+	// continued, so the streamed chunks add up to the stitched response
+	var stream *trailingSpaceStream
+	if cb != nil {
+		stream = &trailingSpaceStream{cb: cb}
+		cb = stream.send
+	}
+
+	resp, err := b.limitedConverse(ctx, modelName, converseInput, input, cb)
+	if err != nil {
+		return resp, err
+	}
+	if !canContinue(resp) {
+		return stream.finish(ctx, resp)
+	}
+
+	// Continuations are sent as an assistant prefill
+	if caps, ok := b.capabilities(ctx, modelName); ok && !caps.Prefill {
+		b.logger().DebugContext(ctx, "bedrock: not continuing output truncated at max tokens, the model does not support assistant prefill", "model", modelName)
+		return stream.finish(ctx, resp)
+	}
+
+	var text strings.Builder
+	text.WriteString(resp.Text())
+	usage := resp.Usage
+	continuations := 0
+
+	for continuations < maxContinuations && canContinue(resp) {
+		// Bedrock rejects an assistant prefill ending with whitespace, so the continuation
+		// picks up after the trimmed text
+		prefill := strings.TrimRightFunc(text.String(), unicode.IsSpace)
+		if prefill == "" {
+			break
+		}
+		text.Reset()
+		text.WriteString(prefill)
+		stream.discard()
+
+		continuations++
+		b.logger().DebugContext(ctx, "bedrock: continuing output truncated at max tokens", "model", modelName, "continuation", continuations)
+
+		next := *converseInput
+		next.Messages = withAssistantPrefill(converseInput.Messages, prefill)
+		resp, err = b.limitedConverse(ctx, modelName, &next, input, cb)
+		if err != nil {
+			partial := &ai.ModelResponse{
+				Message:      ai.NewModelTextMessage(text.String()),
+				FinishReason: ai.FinishReasonLength,
+				Usage:        usage,
+			}
+			var be *Error
+			if errors.As(err, &be) && be.Partial == nil {
+				be.Partial = partial
+			}
+			return nil, err
+		}
+		text.WriteString(resp.Text())
+		usage = addUsage(usage, resp.Usage)
+	}
+
+	resp.Message = ai.NewModelTextMessage(text.String())
+	resp.Usage = usage
+	setResponseMetadata(resp, "continuations", continuations)
+	return stream.finish(ctx, resp)
+}
+
+// canContinue reports whether a response stopped at the maximum output tokens with text only.
+// Other stop reasons reported as FinishReasonLength, such as exceeding the context window, are
+// not continued.
+func canContinue(resp *ai.ModelResponse) bool {
+	if resp == nil || resp.Message == nil || stopReason(resp) != types.StopReasonMaxTokens {
+		return false
+	}
+	return !slices.ContainsFunc(resp.Message.Content, func(part *ai.Part) bool {
+		return !part.IsText()
+	})
+}
+
+// stopReason returns the Bedrock stop reason of a response.
+func stopReason(resp *ai.ModelResponse) types.StopReason {
+	custom, _ := resp.Custom.(map[string]any)
+	reason, _ := custom["stopReason"].(string)
+	return types.StopReason(reason)
+}
+
+// trailingSpaceStream forwards streamed chunks, holding back the trailing whitespace of the
+// text until more output follows.
+type trailingSpaceStream struct {
+	cb      func(context.Context, *ai.ModelResponseChunk) error
+	pending string // Whitespace held back
+}
+
+// send forwards a chunk, sending any held back whitespace before it.
+func (s *trailingSpaceStream) send(ctx context.Context, chunk *ai.ModelResponseChunk) error {
+	content := make([]*ai.Part, 0, len(chunk.Content)+1)
+	for _, part := range chunk.Content {
+		if !part.IsText() {
+			if s.pending != "" {
+				content = append(content, ai.NewTextPart(s.pending))
+				s.pending = ""
+			}
+			content = append(content, part)
+			continue
+		}
+		text := s.pending + part.Text
+		trimmed := strings.TrimRightFunc(text, unicode.IsSpace)
+		s.pending = text[len(trimmed):]
+		if trimmed != "" {
+			textPart := *part
+			textPart.Text = trimmed
+			content = append(content, &textPart)
+		}
+	}
+	if len(content) == 0 {
+		return nil
+	}
+
+	next := *chunk
+	next.Content = content
+	return s.cb(ctx, &next)
+}
+
+// finish sends the held back whitespace once the output is not continued, and returns resp.
+func (s *trailingSpaceStream) finish(ctx context.Context, resp *ai.ModelResponse) (*ai.ModelResponse, error) {
+	if s == nil || s.pending == "" {
+		return resp, nil
+	}
+	chunk := &ai.ModelResponseChunk{
+		Index:   0,
+		Content: []*ai.Part{ai.NewTextPart(s.pending)},
+	}
+	s.pending = ""
+	if err := s.cb(ctx, chunk); err != nil {
+		return nil, fmt.Errorf("callback error: %w", err)
+	}
+	return resp, nil
+}
+
+// discard drops the held back whitespace, which a continuation prefill trims.
+func (s *trailingSpaceStream) discard() {
+	if s != nil {
+		s.pending = ""
+	}
+}
+
+// withAssistantPrefill returns the messages followed by an assistant message starting with
+// text. A trailing assistant message, which is a prefill of its own, is extended instead.
+func withAssistantPrefill(messages []types.Message, text string) []types.Message {
+	block := &types.ContentBlockMemberText{Value: text}
+	messages = slices.Clone(messages)
+	if n := len(messages); n > 0 && messages[n-1].Role == types.ConversationRoleAssistant {
+		content := append(slices.Clone(messages[n-1].Content), block)
+		messages[n-1] = types.Message{Role: types.ConversationRoleAssistant, Content: content}
+		return messages
+	}
+	return append(messages, types.Message{Role: types.ConversationRoleAssistant, Content: []types.ContentBlock{block}})
+}
+
+// addUsage returns the sum of two usages.
+func addUsage(a, b *ai.GenerationUsage) *ai.GenerationUsage {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	sum := &ai.GenerationUsage{
+		InputTokens:         a.InputTokens + b.InputTokens,
+		OutputTokens:        a.OutputTokens + b.OutputTokens,
+		TotalTokens:         a.TotalTokens + b.TotalTokens,
+		CachedContentTokens: a.CachedContentTokens + b.CachedContentTokens,
+	}
+	if a.Custom != nil || b.Custom != nil {
+		sum.Custom = maps.Clone(a.Custom)
+		if sum.Custom == nil {
+			sum.Custom = make(map[string]float64)
+		}
+		for k, v := range b.Custom {
+			sum.Custom[k] += v
+		}
+	}
+	return sum
+}
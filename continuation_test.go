@@ -0,0 +1,202 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+	"github.com/xavidop/genkit-aws-bedrock-go/bedrocktest"
+)
+
+func TestContinuation(t *testing.T) {
+	fake := bedrocktest.NewClient().
+		AddConverse(bedrocktest.Output(types.StopReasonMaxTokens, bedrocktest.TextBlock("Once upon a "))).
+		AddConverse(bedrocktest.Output(types.StopReasonMaxTokens, bedrocktest.TextBlock(" time there"))).
+		AddConverse(bedrocktest.TextOutput(" was a dragon."))
+	b := &bedrock.Bedrock{Client: fake, MaxContinuations: 3}
+	g := newTestPlugin(t, b)
+	m := b.DefineModel(g, bedrock.ModelDefinition{Name: testModel, Type: "chat"}, nil)
+
+	resp, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("Tell me a story"))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	if got, want := resp.Text(), "Once upon a time there was a dragon."; got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+	if resp.FinishReason != ai.FinishReasonStop {
+		t.Errorf("FinishReason = %q, want %q", resp.FinishReason, ai.FinishReasonStop)
+	}
+	if resp.Usage == nil || resp.Usage.InputTokens != 30 || resp.Usage.OutputTokens != 15 {
+		t.Errorf("Usage = %+v, want the usage of all 3 calls", resp.Usage)
+	}
+	if got := resp.Custom.(map[string]any)["continuations"]; got != 2 {
+		t.Errorf("Custom[continuations] = %v, want 2", got)
+	}
+
+	// Each continuation sends the trimmed output so far as an assistant prefill
+	inputs := fake.ConverseInputs()
+	if len(inputs) != 3 {
+		t.Fatalf("got %d Converse calls, want 3", len(inputs))
+	}
+	last := inputs[2].Messages[len(inputs[2].Messages)-1]
+	if last.Role != types.ConversationRoleAssistant {
+		t.Fatalf("last message role = %q, want assistant", last.Role)
+	}
+	if text, ok := last.Content[0].(*types.ContentBlockMemberText); !ok || text.Value != "Once upon a time there" {
+		t.Errorf("prefill = %#v, want %q", last.Content[0], "Once upon a time there")
+	}
+}
+
+func TestContinuationStreamLimit(t *testing.T) {
+	truncated := func(text string) *bedrocktest.Stream {
+		return &bedrocktest.Stream{Events: []types.ConverseStreamOutput{
+			bedrocktest.MessageStart(),
+			bedrocktest.TextDelta(0, text),
+			bedrocktest.BlockStop(0),
+			bedrocktest.MessageStop(types.StopReasonMaxTokens),
+			bedrocktest.Metadata(bedrocktest.Usage(10, 5)),
+		}}
+	}
+	fake := bedrocktest.NewClient().AddStream(truncated("a b")).AddStream(truncated(" c d"))
+	b := &bedrock.Bedrock{Client: fake}
+	g := newTestPlugin(t, b)
+	m := b.DefineModel(g, bedrock.ModelDefinition{Name: testModel, Type: "chat", MaxContinuations: 1}, nil)
+
+	var streamed strings.Builder
+	resp, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithPrompt("Count"),
+		ai.WithStreaming(func(ctx context.Context, chunk *ai.ModelResponseChunk) error {
+			streamed.WriteString(chunk.Text())
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	if got := resp.Text(); got != "a b c d" || streamed.String() != "a b c d" {
+		t.Errorf("Text() = %q, streamed %q, want %q", got, streamed.String(), "a b c d")
+	}
+	// The continuation limit is reached, so the output is still truncated
+	if resp.FinishReason != ai.FinishReasonLength {
+		t.Errorf("FinishReason = %q, want %q", resp.FinishReason, ai.FinishReasonLength)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 30 {
+		t.Errorf("Usage = %+v, want 30 total tokens", resp.Usage)
+	}
+}
+
+func TestContinuationContextWindowExceeded(t *testing.T) {
+	fake := bedrocktest.NewClient().
+		AddConverse(bedrocktest.Output(types.StopReasonModelContextWindowExceeded, bedrocktest.TextBlock("Once upon")))
+	b := &bedrock.Bedrock{Client: fake, MaxContinuations: 3}
+	g := newTestPlugin(t, b)
+	m := b.DefineModel(g, bedrock.ModelDefinition{Name: testModel, Type: "chat"}, nil)
+
+	resp, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("Tell me a story"))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	// A continuation would exceed the context window again, so the output is not continued
+	if n := len(fake.ConverseInputs()); n != 1 {
+		t.Errorf("got %d Converse calls, want 1", n)
+	}
+	if resp.FinishReason != ai.FinishReasonLength {
+		t.Errorf("FinishReason = %q, want %q", resp.FinishReason, ai.FinishReasonLength)
+	}
+	if got := resp.Custom.(map[string]any)["stopReason"]; got != string(types.StopReasonModelContextWindowExceeded) {
+		t.Errorf("Custom[stopReason] = %v, want %q", got, types.StopReasonModelContextWindowExceeded)
+	}
+}
+
+func TestContinuationStreamTrailingWhitespace(t *testing.T) {
+	stream := func(stopReason types.StopReason, deltas ...string) *bedrocktest.Stream {
+		events := []types.ConverseStreamOutput{bedrocktest.MessageStart()}
+		for _, delta := range deltas {
+			events = append(events, bedrocktest.TextDelta(0, delta))
+		}
+		return &bedrocktest.Stream{Events: append(events,
+			bedrocktest.BlockStop(0),
+			bedrocktest.MessageStop(stopReason),
+			bedrocktest.Metadata(bedrocktest.Usage(10, 5)),
+		)}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		streams []*bedrocktest.Stream
+		want    string
+	}{
+		{
+			// The whitespace before the cut is trimmed from the prefill and never streamed
+			name: "continued",
+			streams: []*bedrocktest.Stream{
+				stream(types.StopReasonMaxTokens, "Once ", "upon a", " \n"),
+				stream(types.StopReasonEndTurn, " time."),
+			},
+			want: "Once upon a time.",
+		},
+		{
+			// Whitespace held back is sent once the output is complete
+			name: "not continued",
+			streams: []*bedrocktest.Stream{
+				stream(types.StopReasonEndTurn, "Done. ", "\n"),
+			},
+			want: "Done. \n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := bedrocktest.NewClient()
+			for _, s := range tc.streams {
+				fake.AddStream(s)
+			}
+			b := &bedrock.Bedrock{Client: fake, MaxContinuations: 1}
+			g := newTestPlugin(t, b)
+			m := b.DefineModel(g, bedrock.ModelDefinition{Name: testModel, Type: "chat"}, nil)
+
+			var streamed strings.Builder
+			resp, err := genkit.Generate(context.Background(), g,
+				ai.WithModel(m),
+				ai.WithPrompt("Tell me a story"),
+				ai.WithStreaming(func(ctx context.Context, chunk *ai.ModelResponseChunk) error {
+					streamed.WriteString(chunk.Text())
+					return nil
+				}),
+			)
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+
+			if got := resp.Text(); got != tc.want {
+				t.Errorf("Text() = %q, want %q", got, tc.want)
+			}
+			if got := streamed.String(); got != tc.want {
+				t.Errorf("streamed %q, want the response text %q", got, tc.want)
+			}
+		})
+	}
+}
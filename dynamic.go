@@ -0,0 +1,205 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsbedrock "github.com/aws/aws-sdk-go-v2/service/bedrock"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core/api"
+)
+
+// Bedrock resolves models on demand, so "<namespace>/<model-id>" works without DefineModel.
+var _ api.DynamicPlugin = (*Bedrock)(nil)
+
+// listedModel is a model or inference profile returned by the Bedrock control plane.
+type listedModel struct {
+	ID   string // Model ID or inference profile ID
+	Type string // "chat", "image" or "embedding"
+}
+
+// ListActions lists the foundation models and inference profiles available in the
+// configured region as Genkit models and embedders.
+func (b *Bedrock) ListActions(ctx context.Context) []api.ActionDesc {
+	models, err := b.listModels(ctx)
+	if err != nil {
+		b.logger().WarnContext(ctx, "bedrock: failed to list models, only defined models are available",
+			"region", b.controlClient.Options().Region, "error", err)
+		return nil
+	}
+
+	actions := []api.ActionDesc{}
+	for _, m := range models {
+		if action := b.newAction(m.ID, m.Type); action != nil {
+			actions = append(actions, action.Desc())
+		}
+	}
+	return actions
+}
+
+// ResolveAction resolves a model or embedder by its Bedrock model ID.
+func (b *Bedrock) ResolveAction(atype api.ActionType, name string) api.Action {
+	modelType := b.lookupModelType(name)
+
+	switch atype {
+	case api.ActionTypeEmbedder:
+		if modelType != "embedding" {
+			return nil
+		}
+	case api.ActionTypeModel:
+		if modelType == "embedding" {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	return b.newAction(name, modelType)
+}
+
+// newAction creates an unregistered model or embedder action for the model ID.
+func (b *Bedrock) newAction(modelID, modelType string) api.Action {
+	if modelType == "embedding" {
+		embedder := ai.NewEmbedder(api.NewName(b.Name(), modelID), &ai.EmbedderOptions{
+			Label: b.Name() + "-" + modelID,
+		}, b.embedderFunc(modelID))
+		action, _ := embedder.(api.Action)
+		return action
+	}
+
+	model := ModelDefinition{Name: modelID, Type: modelType}
+	action, _ := ai.NewModel(api.NewName(b.Name(), modelID), b.modelOptions(model, nil), b.modelFunc(model)).(api.Action)
+	return action
+}
+
+// lookupModelType returns the type of a model from the cached listing or the model catalog,
+// falling back to guessing it from the model ID.
+func (b *Bedrock) lookupModelType(modelID string) string {
+	b.listMu.Lock()
+	for _, m := range b.listed {
+		if m.ID == modelID {
+			b.listMu.Unlock()
+			return m.Type
+		}
+	}
+	b.listMu.Unlock()
+
+	if caps, ok := b.capabilities(context.Background(), modelID); ok {
+		switch {
+		case slices.Contains(caps.OutputModalities, ModalityEmbedding):
+			return "embedding"
+		case slices.Contains(caps.OutputModalities, ModalityText):
+			return "chat"
+		case slices.Contains(caps.OutputModalities, ModalityImage):
+			return "image"
+		}
+	}
+	return modelTypeFromID(b.resolveBaseModelID(context.Background(), modelID))
+}
+
+// listModels returns the available foundation models and system-defined inference profiles,
+// caching the result for ModelListTTL.
+func (b *Bedrock) listModels(ctx context.Context) ([]listedModel, error) {
+	b.listMu.Lock()
+	defer b.listMu.Unlock()
+
+	if b.listed != nil && time.Since(b.listedAt) < b.ModelListTTL {
+		return b.listed, nil
+	}
+
+	fmOut, err := b.controlClient.ListFoundationModels(ctx, &awsbedrock.ListFoundationModelsInput{})
+	if err != nil {
+		return nil, newError("ListFoundationModels", "", err)
+	}
+
+	var models []listedModel
+	typesByID := make(map[string]string)
+	for _, fm := range fmOut.ModelSummaries {
+		id := aws.ToString(fm.ModelId)
+		modelType := modelTypeFromModalities(fm.OutputModalities)
+		if modelType == "" {
+			continue
+		}
+		typesByID[id] = modelType
+
+		// Models without on-demand support can only be invoked through an inference profile
+		if slices.Contains(fm.InferenceTypesSupported, bedrocktypes.InferenceTypeOnDemand) {
+			models = append(models, listedModel{ID: id, Type: modelType})
+		}
+	}
+
+	paginator := awsbedrock.NewListInferenceProfilesPaginator(b.controlClient, &awsbedrock.ListInferenceProfilesInput{
+		TypeEquals: bedrocktypes.InferenceProfileTypeSystemDefined,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, newError("ListInferenceProfiles", "", err)
+		}
+		for _, profile := range page.InferenceProfileSummaries {
+			if profile.Status != bedrocktypes.InferenceProfileStatusActive || len(profile.Models) == 0 {
+				continue
+			}
+			modelType, ok := typesByID[BaseModelID(aws.ToString(profile.Models[0].ModelArn))]
+			if !ok {
+				continue
+			}
+			models = append(models, listedModel{ID: aws.ToString(profile.InferenceProfileId), Type: modelType})
+		}
+	}
+
+	b.listed = models
+	b.listedAt = time.Now()
+	return models, nil
+}
+
+// modelTypeFromModalities infers the plugin model type from the output modalities of a
+// foundation model. It returns "" for models the plugin cannot serve.
+func modelTypeFromModalities(outputs []bedrocktypes.ModelModality) string {
+	switch {
+	case slices.Contains(outputs, bedrocktypes.ModelModalityEmbedding):
+		return "embedding"
+	case slices.Contains(outputs, bedrocktypes.ModelModalityText):
+		return "chat"
+	case slices.Contains(outputs, bedrocktypes.ModelModalityImage):
+		return "image"
+	default:
+		return ""
+	}
+}
+
+// modelTypeFromID guesses the plugin model type from a model ID.
+func modelTypeFromID(modelID string) string {
+	switch {
+	case strings.Contains(modelID, "embed"):
+		return "embedding"
+	case strings.Contains(modelID, "titan-image"),
+		strings.Contains(modelID, "nova-canvas"),
+		strings.Contains(modelID, "stable-diffusion"),
+		strings.Contains(modelID, "sd3-"),
+		strings.Contains(modelID, "stable-image"):
+		return "image"
+	default:
+		return "chat"
+	}
+}
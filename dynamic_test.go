@@ -0,0 +1,174 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+	"github.com/xavidop/genkit-aws-bedrock-go/bedrocktest"
+)
+
+// newControlPlane returns a fake Bedrock control plane serving the given JSON responses by path.
+func newControlPlane(t *testing.T, responses map[string]string) (*httptest.Server, *[]string) {
+	t.Helper()
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &paths
+}
+
+func TestResolveModelWithoutDefineModel(t *testing.T) {
+	fake := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("resolved"))
+	g := newTestPlugin(t, &bedrock.Bedrock{Client: fake})
+
+	resp, err := genkit.Generate(context.Background(), g,
+		ai.WithModelName("bedrock/"+testModel),
+		ai.WithPrompt("hi"),
+	)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if got := resp.Text(); got != "resolved" {
+		t.Errorf("Text() = %q, want %q", got, "resolved")
+	}
+	if got := aws.ToString(fake.ConverseInputs()[0].ModelId); got != testModel {
+		t.Errorf("ModelId = %q, want %q", got, testModel)
+	}
+}
+
+func TestResolveEmbedderIsNotAModel(t *testing.T) {
+	g := newTestPlugin(t, &bedrock.Bedrock{Client: bedrocktest.NewClient()})
+
+	if m := genkit.LookupModel(g, "bedrock/amazon.titan-embed-text-v2:0"); m != nil {
+		t.Errorf("LookupModel resolved the embedding model %s as a model", m.Name())
+	}
+	if e := genkit.LookupEmbedder(g, "bedrock/amazon.titan-embed-text-v2:0"); e == nil {
+		t.Error("LookupEmbedder did not resolve the embedding model")
+	}
+}
+
+func TestListActions(t *testing.T) {
+	server, _ := newControlPlane(t, map[string]string{
+		"/foundation-models": `{"modelSummaries":[
+			{"modelId":"amazon.nova-lite-v1:0","outputModalities":["TEXT"],"inferenceTypesSupported":["ON_DEMAND"]},
+			{"modelId":"anthropic.claude-sonnet-4-20250514-v1:0","outputModalities":["TEXT"],"inferenceTypesSupported":["INFERENCE_PROFILE"]},
+			{"modelId":"amazon.titan-embed-text-v2:0","outputModalities":["EMBEDDING"],"inferenceTypesSupported":["ON_DEMAND"]},
+			{"modelId":"example.speech-v1:0","outputModalities":["SPEECH"],"inferenceTypesSupported":["ON_DEMAND"]}
+		]}`,
+		"/inference-profiles": `{"inferenceProfileSummaries":[
+			{"inferenceProfileId":"us.anthropic.claude-sonnet-4-20250514-v1:0","status":"ACTIVE",
+			 "models":[{"modelArn":"arn:aws:bedrock:us-east-1::foundation-model/anthropic.claude-sonnet-4-20250514-v1:0"}]}
+		]}`,
+	})
+	b := &bedrock.Bedrock{
+		Client:    bedrocktest.NewClient(),
+		AWSConfig: &aws.Config{Region: "us-east-1", BaseEndpoint: aws.String(server.URL)},
+		Anonymous: true,
+	}
+	newTestPlugin(t, b)
+
+	var names []string
+	for _, desc := range b.ListActions(context.Background()) {
+		names = append(names, desc.Name)
+	}
+	slices.Sort(names)
+	want := []string{
+		"bedrock/amazon.nova-lite-v1:0",
+		"bedrock/amazon.titan-embed-text-v2:0",
+		"bedrock/us.anthropic.claude-sonnet-4-20250514-v1:0",
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("ListActions names = %q, want %q", names, want)
+	}
+}
+
+func TestNamespacedPlugins(t *testing.T) {
+	us := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("from us"))
+	eu := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("from eu"))
+	usPlugin := &bedrock.Bedrock{Client: us, AWSConfig: &aws.Config{Region: "us-east-1"}}
+	euPlugin := &bedrock.Bedrock{Namespace: "bedrock-eu", Client: eu, AWSConfig: &aws.Config{Region: "eu-west-1"}}
+	g := genkit.Init(context.Background(), genkit.WithPlugins(usPlugin, euPlugin))
+
+	defined := euPlugin.DefineModel(g, bedrock.ModelDefinition{Name: testModel, Type: "chat"}, nil)
+	if got, want := defined.Name(), "bedrock-eu/"+testModel; got != want {
+		t.Errorf("defined model name = %q, want %q", got, want)
+	}
+
+	for _, tc := range []struct {
+		model string
+		want  string
+	}{
+		{"bedrock-eu/" + testModel, "from eu"},
+		{"bedrock/" + testModel, "from us"},
+	} {
+		resp, err := genkit.Generate(context.Background(), g, ai.WithModelName(tc.model), ai.WithPrompt("hi"))
+		if err != nil {
+			t.Fatalf("Generate(%s): %v", tc.model, err)
+		}
+		if got := resp.Text(); got != tc.want {
+			t.Errorf("Generate(%s) Text() = %q, want %q", tc.model, got, tc.want)
+		}
+	}
+}
+
+func TestListActionsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Amzn-Errortype", "AccessDeniedException")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message":"not authorized to perform bedrock:ListFoundationModels"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	var logs bytes.Buffer
+	b := &bedrock.Bedrock{
+		Client:    bedrocktest.NewClient(),
+		AWSConfig: &aws.Config{Region: "eu-west-1", BaseEndpoint: aws.String(server.URL)},
+		Anonymous: true,
+		Logger:    slog.New(slog.NewTextHandler(&logs, nil)),
+	}
+	newTestPlugin(t, b)
+
+	if actions := b.ListActions(context.Background()); len(actions) != 0 {
+		t.Errorf("ListActions = %d actions, want none", len(actions))
+	}
+	for _, want := range []string{"level=WARN", "failed to list models", "region=eu-west-1", "AccessDeniedException"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("log does not contain %q:\n%s", want, logs.String())
+		}
+	}
+}
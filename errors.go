@@ -0,0 +1,264 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/smithy-go"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core"
+)
+
+// Error kinds returned by the plugin. Every failed Bedrock call is reported as an *Error
+// whose Kind is one of these sentinels, so callers can use errors.Is to tell them apart.
+var (
+	ErrThrottled             = errors.New("bedrock: request throttled")
+	ErrRateLimited           = errors.New("bedrock: client-side rate limit exceeded")
+	ErrQuotaExceeded         = errors.New("bedrock: service quota exceeded")
+	ErrAccessDenied          = errors.New("bedrock: access denied")
+	ErrValidation            = errors.New("bedrock: invalid request")
+	ErrContextWindowExceeded = errors.New("bedrock: input exceeds the model context window")
+	ErrModelTimeout          = errors.New("bedrock: model timed out")
+	ErrModelNotReady         = errors.New("bedrock: model not ready")
+	ErrModelNotFound         = errors.New("bedrock: model or resource not found")
+	ErrModelError            = errors.New("bedrock: model error")
+	ErrGuardrailBlocked      = errors.New("bedrock: blocked by guardrail")
+	ErrServiceUnavailable    = errors.New("bedrock: service unavailable")
+	ErrCanceled              = errors.New("bedrock: request canceled")
+	ErrUnknown               = errors.New("bedrock: unknown error")
+)
+
+// Error describes a failed AWS Bedrock call.
+type Error struct {
+	Kind      error           // One of the Err* sentinels
+	Status    core.StatusName // Genkit status the error maps to
+	Operation string          // Bedrock API operation, e.g. "Converse"
+	ModelID   string          // Model ID sent to Bedrock
+	Region    string          // AWS region the call was sent to, when known
+	RequestID string          // AWS request ID, when available
+	Code      string          // AWS error code, e.g. "ThrottlingException"
+	Err       error           // Underlying error
+
+	// Partial holds the content streamed before a ConverseStream call failed, if any.
+	Partial *ai.ModelResponse
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "bedrock %s failed", strings.ToLower(e.Operation))
+	if e.ModelID != "" {
+		fmt.Fprintf(&sb, " for model %s", e.ModelID)
+	}
+	if e.Region != "" {
+		fmt.Fprintf(&sb, " in %s", e.Region)
+	}
+	fmt.Fprintf(&sb, " (%s", e.Status)
+	if e.RequestID != "" {
+		fmt.Fprintf(&sb, ", request ID %s", e.RequestID)
+	}
+	sb.WriteString(")")
+	if e.Err != nil {
+		fmt.Fprintf(&sb, ": %v", e.Err)
+	}
+	return sb.String()
+}
+
+// Unwrap returns the error kind, the underlying error and an equivalent Genkit error,
+// so errors.Is, errors.As and Genkit's status reporting all see through *Error.
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err, e.GenkitError()}
+}
+
+// GenkitError converts the error to a *core.GenkitError carrying the mapped status and
+// the AWS request ID and model ID as details.
+func (e *Error) GenkitError() *core.GenkitError {
+	return &core.GenkitError{
+		Message: e.Error(),
+		Status:  e.Status,
+		Details: map[string]any{
+			"modelId":   e.ModelID,
+			"region":    e.Region,
+			"requestId": e.RequestID,
+			"awsCode":   e.Code,
+		},
+	}
+}
+
+// newError classifies err returned by a Bedrock API operation into an *Error.
+func newError(operation, modelID string, err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	// Keep an existing classification, only filling in missing context. The error may be shared,
+	// e.g. kept for a later fallback or failover error, so a copy is returned for callers to update.
+	var be *Error
+	if errors.As(err, &be) {
+		cp := *be
+		if cp.ModelID == "" {
+			cp.ModelID = modelID
+		}
+		return &cp
+	}
+
+	e := &Error{
+		Operation: operation,
+		ModelID:   modelID,
+		Err:       err,
+	}
+
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		e.RequestID = respErr.ServiceRequestID()
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		e.Code = apiErr.ErrorCode()
+	}
+
+	e.Kind, e.Status = classifyError(err, respErr)
+	return e
+}
+
+// classifyError maps err to an error kind and Genkit status.
+func classifyError(err error, respErr *awshttp.ResponseError) (error, core.StatusName) {
+	var (
+		throttling     *types.ThrottlingException
+		quota          *types.ServiceQuotaExceededException
+		accessDenied   *types.AccessDeniedException
+		validation     *types.ValidationException
+		modelTimeout   *types.ModelTimeoutException
+		modelNotReady  *types.ModelNotReadyException
+		notFound       *types.ResourceNotFoundException
+		modelErr       *types.ModelErrorException
+		modelStreamErr *types.ModelStreamErrorException
+		unavailable    *types.ServiceUnavailableException
+		internal       *types.InternalServerException
+	)
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrCanceled, core.CANCELLED
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrModelTimeout, core.DEADLINE_EXCEEDED
+	case errors.As(err, &throttling):
+		return ErrThrottled, core.RESOURCE_EXHAUSTED
+	case errors.As(err, &quota):
+		return ErrQuotaExceeded, core.RESOURCE_EXHAUSTED
+	case errors.As(err, &accessDenied):
+		return ErrAccessDenied, core.PERMISSION_DENIED
+	case errors.As(err, &validation):
+		if isContextWindowMessage(validation.ErrorMessage()) {
+			return ErrContextWindowExceeded, core.OUT_OF_RANGE
+		}
+		return ErrValidation, core.INVALID_ARGUMENT
+	case errors.As(err, &modelTimeout):
+		return ErrModelTimeout, core.DEADLINE_EXCEEDED
+	case errors.As(err, &modelNotReady):
+		return ErrModelNotReady, core.UNAVAILABLE
+	case errors.As(err, &notFound):
+		return ErrModelNotFound, core.NOT_FOUND
+	case errors.As(err, &modelErr):
+		return ErrModelError, core.INTERNAL
+	case errors.As(err, &modelStreamErr):
+		return ErrModelError, core.INTERNAL
+	case errors.As(err, &unavailable):
+		return ErrServiceUnavailable, core.UNAVAILABLE
+	case errors.As(err, &internal):
+		// Bedrock internal errors are transient, like HTTP 5xx responses without a modeled exception
+		return ErrServiceUnavailable, core.UNAVAILABLE
+	}
+
+	// Fall back to the HTTP status code for errors without a modeled exception
+	if respErr != nil {
+		switch code := respErr.HTTPStatusCode(); {
+		case code == http.StatusTooManyRequests:
+			return ErrThrottled, core.RESOURCE_EXHAUSTED
+		case code == http.StatusForbidden:
+			return ErrAccessDenied, core.PERMISSION_DENIED
+		case code == http.StatusUnauthorized:
+			return ErrAccessDenied, core.UNAUTHENTICATED
+		case code == http.StatusNotFound:
+			return ErrModelNotFound, core.NOT_FOUND
+		case code == http.StatusBadRequest:
+			return ErrValidation, core.INVALID_ARGUMENT
+		case code >= http.StatusInternalServerError:
+			return ErrServiceUnavailable, core.UNAVAILABLE
+		}
+	}
+
+	return ErrUnknown, core.UNKNOWN
+}
+
+// isContextWindowMessage reports whether a validation message refers to the input being
+// larger than the model context window. Bedrock does not model this as a separate exception.
+func isContextWindowMessage(msg string) bool {
+	msg = strings.ToLower(msg)
+	for _, s := range []string{
+		"input is too long",
+		"prompt is too long",
+		"too many input tokens",
+		"too many total text bytes",
+		"context window",
+		"context length",
+		"maximum context",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// errStreamTruncated is the underlying error for streams that end without a message stop event.
+var errStreamTruncated = errors.New("stream ended before the message was complete")
+
+// newStreamError classifies an error that interrupted a ConverseStream response and attaches
+// the content received so far.
+func newStreamError(modelID string, err error, partial *ai.ModelResponse) *Error {
+	e := newError("ConverseStream", modelID, err)
+	if errors.Is(err, errStreamTruncated) {
+		e.Kind, e.Status = ErrModelError, core.DATA_LOSS
+	}
+	e.Partial = partial
+	return e
+}
+
+// newGuardrailError reports a response that was stopped by a Bedrock guardrail.
+func newGuardrailError(operation, modelID string, output string) *Error {
+	msg := "guardrail intervened"
+	if output != "" {
+		msg += ": " + output
+	}
+	return &Error{
+		Kind:      ErrGuardrailBlocked,
+		Status:    core.FAILED_PRECONDITION,
+		Operation: operation,
+		ModelID:   modelID,
+		Code:      string(types.StopReasonGuardrailIntervened),
+		Err:       errors.New(msg),
+	}
+}
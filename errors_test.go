@@ -0,0 +1,177 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+	"github.com/xavidop/genkit-aws-bedrock-go/bedrocktest"
+)
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantKind   error
+		wantStatus core.StatusName
+	}{
+		{"throttling", &types.ThrottlingException{Message: aws.String("slow down")}, bedrock.ErrThrottled, core.RESOURCE_EXHAUSTED},
+		{"quota", &types.ServiceQuotaExceededException{Message: aws.String("quota")}, bedrock.ErrQuotaExceeded, core.RESOURCE_EXHAUSTED},
+		{"access denied", &types.AccessDeniedException{Message: aws.String("denied")}, bedrock.ErrAccessDenied, core.PERMISSION_DENIED},
+		{"validation", &types.ValidationException{Message: aws.String("bad request")}, bedrock.ErrValidation, core.INVALID_ARGUMENT},
+		{"context window", &types.ValidationException{Message: aws.String("Input is too long for requested model.")}, bedrock.ErrContextWindowExceeded, core.OUT_OF_RANGE},
+		{"model timeout", &types.ModelTimeoutException{Message: aws.String("timeout")}, bedrock.ErrModelTimeout, core.DEADLINE_EXCEEDED},
+		{"not found", &types.ResourceNotFoundException{Message: aws.String("missing")}, bedrock.ErrModelNotFound, core.NOT_FOUND},
+		{"unavailable", &types.ServiceUnavailableException{Message: aws.String("down")}, bedrock.ErrServiceUnavailable, core.UNAVAILABLE},
+		{"internal", &types.InternalServerException{Message: aws.String("internal")}, bedrock.ErrServiceUnavailable, core.UNAVAILABLE},
+		{"http 429", bedrocktest.ResponseError(http.StatusTooManyRequests, "req-1", errors.New("too many requests")), bedrock.ErrThrottled, core.RESOURCE_EXHAUSTED},
+		{"unknown", errors.New("boom"), bedrock.ErrUnknown, core.UNKNOWN},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := bedrocktest.NewClient().AddConverseError(tt.err)
+			g, m := defineTestModel(t, fake, testModel)
+
+			_, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("hi"))
+			if !errors.Is(err, tt.wantKind) {
+				t.Fatalf("error = %v, want kind %v", err, tt.wantKind)
+			}
+			var be *bedrock.Error
+			if !errors.As(err, &be) {
+				t.Fatalf("error = %T, want *bedrock.Error", err)
+			}
+			if be.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s", be.Status, tt.wantStatus)
+			}
+			if be.ModelID != testModel || be.Operation != "Converse" {
+				t.Errorf("ModelID, Operation = %q, %q, want %q, Converse", be.ModelID, be.Operation, testModel)
+			}
+		})
+	}
+}
+
+func TestErrorClassificationKept(t *testing.T) {
+	shared := &bedrock.Error{
+		Kind:      bedrock.ErrValidation,
+		Status:    core.INVALID_ARGUMENT,
+		Operation: "Converse",
+		Err:       errors.New("bad request"),
+	}
+	fake := bedrocktest.NewClient().AddConverseError(shared)
+	g, m := defineTestModel(t, fake, testModel)
+
+	_, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("hi"))
+	var be *bedrock.Error
+	if !errors.As(err, &be) || be.Kind != bedrock.ErrValidation || be.ModelID != testModel {
+		t.Fatalf("error = %#v, want the validation error with model ID %s", err, testModel)
+	}
+	// The model ID is filled in on a copy, the original error is left as it was
+	if shared.ModelID != "" {
+		t.Errorf("shared error ModelID = %q, want it unchanged", shared.ModelID)
+	}
+}
+
+func TestErrorRequestID(t *testing.T) {
+	throttled := &types.ThrottlingException{Message: aws.String("slow down")}
+	fake := bedrocktest.NewClient().
+		AddConverseError(bedrocktest.ResponseError(http.StatusTooManyRequests, "req-123", throttled))
+	g, m := defineTestModel(t, fake, testModel)
+
+	_, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("hi"))
+	var be *bedrock.Error
+	if !errors.As(err, &be) {
+		t.Fatalf("error = %v, want *bedrock.Error", err)
+	}
+	if be.RequestID != "req-123" || be.Code != "ThrottlingException" {
+		t.Errorf("RequestID, Code = %q, %q, want req-123, ThrottlingException", be.RequestID, be.Code)
+	}
+	if got := be.GenkitError().Details["requestId"]; got != "req-123" {
+		t.Errorf("GenkitError details requestId = %v, want req-123", got)
+	}
+}
+
+func TestGuardrailIntervened(t *testing.T) {
+	fake := bedrocktest.NewClient().
+		AddConverse(bedrocktest.Output(types.StopReasonGuardrailIntervened, bedrocktest.TextBlock("Sorry, I can't help with that.")))
+	g, m := defineTestModel(t, fake, testModel)
+
+	_, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("hi"))
+	if !errors.Is(err, bedrock.ErrGuardrailBlocked) {
+		t.Fatalf("error = %v, want ErrGuardrailBlocked", err)
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	t.Run("mid-stream exception", func(t *testing.T) {
+		stream := &bedrocktest.Stream{
+			Events: []types.ConverseStreamOutput{
+				bedrocktest.MessageStart(),
+				bedrocktest.TextDelta(0, "Partial "),
+				bedrocktest.TextDelta(0, "answer"),
+			},
+			Err: &types.ModelStreamErrorException{Message: aws.String("stream broke")},
+		}
+		fake := bedrocktest.NewClient().AddStream(stream)
+		g, m := defineTestModel(t, fake, testModel)
+
+		_, err := genkit.Generate(context.Background(), g,
+			ai.WithModel(m),
+			ai.WithPrompt("hi"),
+			ai.WithStreaming(func(context.Context, *ai.ModelResponseChunk) error { return nil }),
+		)
+		var be *bedrock.Error
+		if !errors.As(err, &be) || !errors.Is(err, bedrock.ErrModelError) {
+			t.Fatalf("error = %v, want *bedrock.Error of kind ErrModelError", err)
+		}
+		if be.Partial == nil || be.Partial.Text() != "Partial answer" {
+			t.Errorf("Partial = %+v, want the streamed text", be.Partial)
+		}
+		if !stream.Closed() {
+			t.Error("stream was not closed")
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		fake := bedrocktest.NewClient().AddStream(&bedrocktest.Stream{
+			Events: []types.ConverseStreamOutput{
+				bedrocktest.MessageStart(),
+				bedrocktest.TextDelta(0, "Cut"),
+			},
+		})
+		g, m := defineTestModel(t, fake, testModel)
+
+		_, err := genkit.Generate(context.Background(), g,
+			ai.WithModel(m),
+			ai.WithPrompt("hi"),
+			ai.WithStreaming(func(context.Context, *ai.ModelResponseChunk) error { return nil }),
+		)
+		var be *bedrock.Error
+		if !errors.As(err, &be) || be.Status != core.DATA_LOSS {
+			t.Fatalf("error = %v, want *bedrock.Error with status DATA_LOSS", err)
+		}
+	})
+}
@@ -0,0 +1,164 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core/api"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// DefineFallbackModel defines a text model that serves each request with the first of the
+// given Bedrock models that can handle it, falling back to the next model when one is
+// throttled, not accessible or the request exceeds its context window. Models whose
+// capabilities don't match the request (e.g. tools or images) are skipped.
+//
+// The model that served the response is reported in response.Custom["model"], and the number
+// of models called before it in response.Custom["fallbackAttempts"].
+func (b *Bedrock) DefineFallbackModel(g *genkit.Genkit, name string, modelIDs []string, info *ai.ModelInfo) ai.Model {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.initted {
+		panic("bedrock: Init not called")
+	}
+	if len(modelIDs) == 0 {
+		panic("bedrock: DefineFallbackModel requires at least one model")
+	}
+
+	// Advertise everything any candidate supports; candidates are checked per request
+	if info == nil {
+		info = &ai.ModelInfo{Supports: &ai.ModelSupports{}}
+		for _, modelID := range modelIDs {
+			s := b.inferModelCapabilities(modelID, "chat").Supports
+			info.Supports.Multiturn = info.Supports.Multiturn || s.Multiturn
+			info.Supports.Tools = info.Supports.Tools || s.Tools
+			info.Supports.SystemRole = info.Supports.SystemRole || s.SystemRole
+			info.Supports.Media = info.Supports.Media || s.Media
+		}
+	}
+
+	meta := &ai.ModelOptions{
+		Label:    b.Name() + "-" + name,
+		Supports: info.Supports,
+		Versions: info.Versions,
+	}
+
+	return genkit.DefineModel(g, api.NewName(b.Name(), name), meta, func(
+		ctx context.Context,
+		input *ai.ModelRequest,
+		cb func(context.Context, *ai.ModelResponseChunk) error,
+	) (*ai.ModelResponse, error) {
+		return b.generateWithFallback(ctx, modelIDs, input, cb, nil, "")
+	})
+}
+
+// FallbackMiddleware returns a model middleware that retries a failed request on the given
+// Bedrock models, in order, when the wrapped model fails with an error that another model
+// could avoid. It can wrap any Genkit model, which must be passed as model so the response
+// can report it in response.Custom["model"] (without the plugin prefix for Bedrock models):
+//
+//	genkit.Generate(ctx, g,
+//		ai.WithModel(claude),
+//		ai.WithMiddleware(bedrockPlugin.FallbackMiddleware(claude, "amazon.nova-pro-v1:0")),
+//	)
+func (b *Bedrock) FallbackMiddleware(model ai.Model, modelIDs ...string) ai.ModelMiddleware {
+	name := strings.TrimPrefix(model.Name(), b.Name()+"/")
+	return func(next ai.ModelFunc) ai.ModelFunc {
+		return func(ctx context.Context, input *ai.ModelRequest, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+			return b.generateWithFallback(ctx, modelIDs, input, cb, next, name)
+		}
+	}
+}
+
+// generateWithFallback tries first (if set, reported as firstName) and then each model in
+// turn until one succeeds.
+func (b *Bedrock) generateWithFallback(ctx context.Context, modelIDs []string, input *ai.ModelRequest, cb ai.ModelStreamCallback, first ai.ModelFunc, firstName string) (*ai.ModelResponse, error) {
+	var errs []error
+	attempts := 0 // Candidates called, not counting those skipped as incapable
+
+	// try runs one candidate. Once a candidate has streamed chunks to the caller, its
+	// failure can no longer be retried on another model.
+	try := func(fn ai.ModelFunc) (*ai.ModelResponse, bool, error) {
+		streamed := false
+		var candidateCb ai.ModelStreamCallback
+		if cb != nil {
+			candidateCb = func(ctx context.Context, chunk *ai.ModelResponseChunk) error {
+				streamed = true
+				return cb(ctx, chunk)
+			}
+		}
+		attempts++
+		resp, err := fn(ctx, input, candidateCb)
+		return resp, err == nil || streamed || !isFallbackError(err) || ctx.Err() != nil, err
+	}
+
+	if first != nil {
+		resp, done, err := try(first)
+		if err == nil {
+			setResponseMetadata(resp, "model", firstName)
+			setResponseMetadata(resp, "fallbackAttempts", 0)
+			return resp, nil
+		}
+		if done {
+			return nil, err
+		}
+		b.logger().WarnContext(ctx, "bedrock: model failed, falling back to Bedrock models", "model", firstName, "error", err)
+		errs = append(errs, err)
+	}
+
+	for i, modelID := range modelIDs {
+		if caps, ok := b.capabilities(ctx, modelID); ok {
+			if err := validateRequest(modelID, caps, input, cb != nil); err != nil {
+				b.logger().DebugContext(ctx, "bedrock: skipping fallback model", "model", modelID, "reason", err)
+				errs = append(errs, err)
+				continue
+			}
+		}
+
+		resp, done, err := try(func(ctx context.Context, input *ai.ModelRequest, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+			return b.generateText(ctx, modelID, input, cb)
+		})
+		if err == nil {
+			setResponseMetadata(resp, "model", modelID)
+			setResponseMetadata(resp, "fallbackAttempts", attempts-1)
+			return resp, nil
+		}
+		if done || i == len(modelIDs)-1 {
+			return nil, err
+		}
+		b.logger().WarnContext(ctx, "bedrock: model failed, falling back to next model", "model", modelID, "error", err)
+		errs = append(errs, err)
+	}
+
+	return nil, fmt.Errorf("bedrock: no fallback model could serve the request: %w", errors.Join(errs...))
+}
+
+// isFallbackError reports whether a failed request may succeed on a different model.
+func isFallbackError(err error) bool {
+	return isFailoverError(err) ||
+		errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, ErrAccessDenied) ||
+		errors.Is(err, ErrContextWindowExceeded) ||
+		errors.Is(err, ErrModelNotFound) ||
+		errors.Is(err, ErrModelError)
+}
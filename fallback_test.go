@@ -0,0 +1,128 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+	"github.com/xavidop/genkit-aws-bedrock-go/bedrocktest"
+)
+
+func TestFallbackModel(t *testing.T) {
+	const fallbackModel = "amazon.nova-pro-v1:0"
+	fake := bedrocktest.NewClient().
+		AddConverseError(&types.AccessDeniedException{Message: aws.String("no access")}).
+		AddConverse(bedrocktest.TextOutput("from nova"))
+	b := &bedrock.Bedrock{Client: fake}
+	g := newTestPlugin(t, b)
+	m := b.DefineFallbackModel(g, "resilient", []string{testModel, fallbackModel}, nil)
+
+	resp, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("hi"))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if got := resp.Text(); got != "from nova" {
+		t.Errorf("Text() = %q, want the fallback model response", got)
+	}
+	custom := resp.Custom.(map[string]any)
+	if custom["model"] != fallbackModel || custom["fallbackAttempts"] != 1 {
+		t.Errorf("Custom = %v, want model %s after 1 attempt", custom, fallbackModel)
+	}
+
+	inputs := fake.ConverseInputs()
+	if len(inputs) != 2 || aws.ToString(inputs[1].ModelId) != fallbackModel {
+		t.Errorf("got %d Converse calls, want a second one for %s", len(inputs), fallbackModel)
+	}
+}
+
+func TestFallbackSkipsIncapableModels(t *testing.T) {
+	// Titan Text does not support tools, so the request goes straight to Claude
+	fake := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("from claude"))
+	b := &bedrock.Bedrock{Client: fake}
+	g := newTestPlugin(t, b)
+	m := b.DefineFallbackModel(g, "tools", []string{"amazon.titan-text-express-v1", testModel}, nil)
+	tool := genkit.DefineTool(g, "noop", "Does nothing", func(ctx *ai.ToolContext, input struct{}) (string, error) {
+		return "", nil
+	})
+
+	resp, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithPrompt("hi"),
+		ai.WithTools(tool),
+	)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	// Skipped models are not counted as attempts
+	custom := resp.Custom.(map[string]any)
+	if custom["model"] != testModel || custom["fallbackAttempts"] != 0 {
+		t.Errorf("Custom = %v, want model %s after 0 attempts", custom, testModel)
+	}
+	if n := len(fake.ConverseInputs()); n != 1 {
+		t.Errorf("got %d Converse calls, want 1", n)
+	}
+}
+
+func TestFallbackMiddleware(t *testing.T) {
+	const fallbackModel = "amazon.nova-pro-v1:0"
+	for _, tc := range []struct {
+		name         string
+		fake         *bedrocktest.Client
+		wantModel    string
+		wantAttempts int
+	}{
+		{
+			name:         "primary answers",
+			fake:         bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("from claude")),
+			wantModel:    testModel,
+			wantAttempts: 0,
+		},
+		{
+			name: "primary fails",
+			fake: bedrocktest.NewClient().
+				AddConverseError(&types.ThrottlingException{Message: aws.String("slow down")}).
+				AddConverse(bedrocktest.TextOutput("from nova")),
+			wantModel:    fallbackModel,
+			wantAttempts: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := &bedrock.Bedrock{Client: tc.fake}
+			g := newTestPlugin(t, b)
+			m := b.DefineModel(g, bedrock.ModelDefinition{Name: testModel, Type: "chat"}, nil)
+
+			resp, err := genkit.Generate(context.Background(), g,
+				ai.WithModel(m),
+				ai.WithMiddleware(b.FallbackMiddleware(m, fallbackModel)),
+				ai.WithPrompt("hi"),
+			)
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			custom := resp.Custom.(map[string]any)
+			if custom["model"] != tc.wantModel || custom["fallbackAttempts"] != tc.wantAttempts {
+				t.Errorf("Custom = %v, want model %s after %d attempts", custom, tc.wantModel, tc.wantAttempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsbedrock "github.com/aws/aws-sdk-go-v2/service/bedrock"
+)
+
+// inferenceProfilePrefixes are the geographic prefixes of cross-region inference profile IDs,
+// e.g. "us.anthropic.claude-sonnet-4-20250514-v1:0".
+var inferenceProfilePrefixes = []string{
+	"us.", "us-gov.", "eu.", "apac.", "jp.", "au.", "ca.", "global.",
+}
+
+// Resource types of Bedrock ARNs that can be used as model IDs.
+const (
+	arnFoundationModel             = "foundation-model"
+	arnInferenceProfile            = "inference-profile"
+	arnApplicationInferenceProfile = "application-inference-profile"
+	arnProvisionedModel            = "provisioned-model"
+)
+
+// BaseModelID returns the foundation model ID behind a Bedrock model identifier, stripping
+// cross-region inference profile prefixes and foundation model or system-defined inference
+// profile ARNs. Identifiers it cannot resolve locally are returned unchanged.
+//
+//	BaseModelID("us.anthropic.claude-sonnet-4-20250514-v1:0") // "anthropic.claude-sonnet-4-20250514-v1:0"
+func BaseModelID(modelID string) string {
+	if resourceType, resourceID, ok := parseBedrockARN(modelID); ok {
+		switch resourceType {
+		case arnFoundationModel, arnInferenceProfile:
+			modelID = resourceID
+		default:
+			return modelID
+		}
+	}
+
+	for _, prefix := range inferenceProfilePrefixes {
+		if strings.HasPrefix(modelID, prefix) {
+			return strings.TrimPrefix(modelID, prefix)
+		}
+	}
+	return modelID
+}
+
+// parseBedrockARN splits a Bedrock ARN such as
+// "arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/abc123" into its
+// resource type and ID.
+func parseBedrockARN(s string) (resourceType, resourceID string, ok bool) {
+	if !strings.HasPrefix(s, "arn:") {
+		return "", "", false
+	}
+	parts := strings.SplitN(s, ":", 6)
+	if len(parts) != 6 || parts[2] != "bedrock" {
+		return "", "", false
+	}
+	return strings.Cut(parts[5], "/")
+}
+
+// baseModelRetry is how long a failed base model lookup is cached before it is retried.
+const baseModelRetry = 30 * time.Second
+
+// baseModelLookup is a control plane lookup of the base model of an application inference
+// profile or provisioned throughput. Concurrent callers share the lookup of an ID.
+type baseModelLookup struct {
+	done    chan struct{} // Closed when the lookup completes
+	base    string        // Base model ID, "" if the lookup failed
+	retryAt time.Time     // When a failed lookup may be retried
+}
+
+// resolveBaseModelID is like BaseModelID but also resolves application inference profile and
+// provisioned throughput ARNs using the Bedrock control plane. Results are cached, and
+// failures are cached for baseModelRetry.
+func (b *Bedrock) resolveBaseModelID(ctx context.Context, modelID string) string {
+	resourceType, _, ok := parseBedrockARN(modelID)
+	if !ok || (resourceType != arnApplicationInferenceProfile && resourceType != arnProvisionedModel) {
+		return BaseModelID(modelID)
+	}
+	if b.controlClient == nil {
+		return modelID
+	}
+
+	for {
+		b.profileMu.Lock()
+		lookup, ok := b.baseModelIDs[modelID]
+		if !ok {
+			// Look the model up without holding the lock, so other IDs aren't blocked
+			lookup = &baseModelLookup{done: make(chan struct{})}
+			if b.baseModelIDs == nil {
+				b.baseModelIDs = make(map[string]*baseModelLookup)
+			}
+			b.baseModelIDs[modelID] = lookup
+			b.profileMu.Unlock()
+
+			base, err := b.lookupBaseModelID(ctx, resourceType, modelID)
+			if err != nil {
+				b.logger().DebugContext(ctx, "bedrock: failed to resolve the base model", "model", modelID, "error", err)
+				lookup.retryAt = time.Now().Add(baseModelRetry)
+				if ctx.Err() != nil {
+					// A canceled caller doesn't hold back others
+					lookup.retryAt = time.Now()
+				}
+				close(lookup.done)
+				return modelID
+			}
+			lookup.base = base
+			close(lookup.done)
+			return base
+		}
+		b.profileMu.Unlock()
+
+		select {
+		case <-lookup.done:
+		case <-ctx.Done():
+			return modelID
+		}
+		if lookup.base != "" {
+			return lookup.base
+		}
+		if time.Now().Before(lookup.retryAt) {
+			return modelID
+		}
+
+		// The failure has expired, look the model up again
+		b.profileMu.Lock()
+		if b.baseModelIDs[modelID] == lookup {
+			delete(b.baseModelIDs, modelID)
+		}
+		b.profileMu.Unlock()
+	}
+}
+
+// lookupBaseModelID calls the control plane for the base model of an application inference
+// profile or provisioned throughput.
+func (b *Bedrock) lookupBaseModelID(ctx context.Context, resourceType, modelID string) (string, error) {
+	optFns, err := b.controlCallOptions(ctx)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, b.RequestTimeout)
+	defer cancel()
+
+	var foundationModelARN string
+	switch resourceType {
+	case arnApplicationInferenceProfile:
+		out, err := b.controlClient.GetInferenceProfile(ctx, &awsbedrock.GetInferenceProfileInput{
+			InferenceProfileIdentifier: aws.String(modelID),
+		}, optFns...)
+		if err != nil {
+			return "", newError("GetInferenceProfile", modelID, err)
+		}
+		if len(out.Models) == 0 {
+			return "", fmt.Errorf("inference profile %s has no models", modelID)
+		}
+		foundationModelARN = aws.ToString(out.Models[0].ModelArn)
+	case arnProvisionedModel:
+		out, err := b.controlClient.GetProvisionedModelThroughput(ctx, &awsbedrock.GetProvisionedModelThroughputInput{
+			ProvisionedModelId: aws.String(modelID),
+		}, optFns...)
+		if err != nil {
+			return "", newError("GetProvisionedModelThroughput", modelID, err)
+		}
+		foundationModelARN = aws.ToString(out.FoundationModelArn)
+	}
+	return BaseModelID(foundationModelARN), nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+	"github.com/xavidop/genkit-aws-bedrock-go/bedrocktest"
+)
+
+func TestBaseModelID(t *testing.T) {
+	for _, tc := range []struct {
+		modelID string
+		want    string
+	}{
+		{"anthropic.claude-sonnet-4-20250514-v1:0", "anthropic.claude-sonnet-4-20250514-v1:0"},
+		{"us.anthropic.claude-sonnet-4-20250514-v1:0", "anthropic.claude-sonnet-4-20250514-v1:0"},
+		{"global.anthropic.claude-sonnet-4-5-20250929-v1:0", "anthropic.claude-sonnet-4-5-20250929-v1:0"},
+		{"arn:aws:bedrock:us-east-1::foundation-model/amazon.nova-pro-v1:0", "amazon.nova-pro-v1:0"},
+		{"arn:aws:bedrock:us-east-1:123456789012:inference-profile/eu.amazon.nova-pro-v1:0", "amazon.nova-pro-v1:0"},
+		{"arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/abc123",
+			"arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/abc123"},
+		{"arn:aws:s3:::bucket/us.object", "arn:aws:s3:::bucket/us.object"},
+	} {
+		if got := bedrock.BaseModelID(tc.modelID); got != tc.want {
+			t.Errorf("BaseModelID(%q) = %q, want %q", tc.modelID, got, tc.want)
+		}
+	}
+}
+
+func TestCrossRegionProfileUsesBaseModelCapabilities(t *testing.T) {
+	b := &bedrock.Bedrock{Client: bedrocktest.NewClient()}
+	newTestPlugin(t, b)
+
+	caps, ok := b.ModelCapabilities(context.Background(), "us."+testModel)
+	if !ok {
+		t.Fatal("cross-region inference profile not found in the catalog")
+	}
+	base, _ := b.ModelCapabilities(context.Background(), testModel)
+	if caps.Tools != base.Tools || caps.ContextWindow != base.ContextWindow {
+		t.Errorf("profile capabilities = %+v, want those of %s", caps, testModel)
+	}
+}
+
+func TestApplicationInferenceProfileResolution(t *testing.T) {
+	const profileARN = "arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/abc123"
+	server, paths := newControlPlane(t, map[string]string{
+		"/inference-profiles/" + profileARN: `{"models":[
+			{"modelArn":"arn:aws:bedrock:us-east-1::foundation-model/` + testModel + `"}
+		]}`,
+	})
+	b := &bedrock.Bedrock{
+		Client:    bedrocktest.NewClient(),
+		AWSConfig: &aws.Config{Region: "us-east-1", BaseEndpoint: aws.String(server.URL)},
+		Anonymous: true,
+	}
+	newTestPlugin(t, b)
+
+	for range 2 {
+		caps, ok := b.ModelCapabilities(context.Background(), profileARN)
+		if !ok {
+			t.Fatal("application inference profile not resolved to a catalog model")
+		}
+		if !caps.Tools {
+			t.Errorf("profile capabilities = %+v, want those of %s", caps, testModel)
+		}
+	}
+	if len(*paths) != 1 {
+		t.Errorf("control plane called %d times, want 1 (results are cached)", len(*paths))
+	}
+}
+
+func TestApplicationInferenceProfileGenerate(t *testing.T) {
+	const profileARN = "arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/abc123"
+	server, _ := newControlPlane(t, map[string]string{
+		"/inference-profiles/" + profileARN: `{"models":[
+			{"modelArn":"arn:aws:bedrock:us-east-1::foundation-model/` + testModel + `"}
+		]}`,
+	})
+	fake := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("via profile"))
+	b := &bedrock.Bedrock{
+		Client:    fake,
+		AWSConfig: &aws.Config{Region: "us-east-1", BaseEndpoint: aws.String(server.URL)},
+		Anonymous: true,
+	}
+	g := newTestPlugin(t, b)
+	m := b.DefineModel(g, bedrock.ModelDefinition{Name: profileARN, Type: "chat"}, nil)
+
+	resp, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("hi"))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if got := resp.Text(); got != "via profile" {
+		t.Errorf("Text() = %q, want %q", got, "via profile")
+	}
+	if got := aws.ToString(fake.ConverseInputs()[0].ModelId); got != profileARN {
+		t.Errorf("ModelId = %q, want the profile ARN", got)
+	}
+}
+
+func TestApplicationInferenceProfileFailureCached(t *testing.T) {
+	const profileARN = "arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/missing"
+	server, paths := newControlPlane(t, nil)
+	b := &bedrock.Bedrock{
+		Client:    bedrocktest.NewClient(),
+		AWSConfig: &aws.Config{Region: "us-east-1", BaseEndpoint: aws.String(server.URL)},
+		Anonymous: true,
+	}
+	newTestPlugin(t, b)
+
+	for range 2 {
+		if _, ok := b.ModelCapabilities(context.Background(), profileARN); ok {
+			t.Fatal("unknown application inference profile resolved to a catalog model")
+		}
+	}
+	if len(*paths) != 1 {
+		t.Errorf("control plane called %d times, want 1 (failures are cached)", len(*paths))
+	}
+}
+
+func TestApplicationInferenceProfileConcurrentLookups(t *testing.T) {
+	const (
+		slowARN = "arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/slow"
+		fastARN = "arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/fast"
+	)
+	arrived, release := make(chan struct{}), make(chan struct{})
+	var mu sync.Mutex
+	calls := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		first := calls[r.URL.Path] == 1
+		mu.Unlock()
+		if r.URL.Path == "/inference-profiles/"+slowARN {
+			if first {
+				close(arrived)
+			}
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"models":[{"modelArn":"arn:aws:bedrock:us-east-1::foundation-model/` + testModel + `"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	b := &bedrock.Bedrock{
+		Client:    bedrocktest.NewClient(),
+		AWSConfig: &aws.Config{Region: "us-east-1", BaseEndpoint: aws.String(server.URL)},
+		Anonymous: true,
+	}
+	newTestPlugin(t, b)
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := b.ModelCapabilities(context.Background(), slowARN); !ok {
+				t.Error("slow profile not resolved")
+			}
+		}()
+	}
+	<-arrived
+
+	// Another profile resolves while the slow lookup is in flight
+	if _, ok := b.ModelCapabilities(context.Background(), fastARN); !ok {
+		t.Error("fast profile not resolved")
+	}
+	close(release)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if n := calls["/inference-profiles/"+slowARN]; n != 1 {
+		t.Errorf("slow profile looked up %d times, want 1 shared lookup", n)
+	}
+}
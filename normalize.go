@@ -0,0 +1,162 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// Text of the messages and results inserted to repair a conversation.
+const (
+	placeholderUserText      = "Continue the conversation."
+	placeholderToolResultMsg = "The tool result is not available."
+)
+
+// normalizeMessages repairs a conversation so it follows the Converse rules: it starts with a
+// user message, roles alternate, and the results of an assistant's tool calls are at the start
+// of the next user message. Adjacent messages with the same role are merged, tool results
+// are moved next to their tool calls, and missing ones are reported as failed. It returns an
+// error if a tool result doesn't match any tool call.
+func normalizeMessages(messages []types.Message) ([]types.Message, error) {
+	// Take the tool results out of their messages, to put them back after their tool calls
+	toolUses := map[string]bool{}
+	for _, msg := range messages {
+		for _, block := range msg.Content {
+			if toolUse, ok := block.(*types.ContentBlockMemberToolUse); ok {
+				toolUses[aws.ToString(toolUse.Value.ToolUseId)] = true
+			}
+		}
+	}
+	results := map[string]*types.ContentBlockMemberToolResult{}
+	var stripped []types.Message
+	for _, msg := range messages {
+		var content []types.ContentBlock
+		for _, block := range msg.Content {
+			result, ok := block.(*types.ContentBlockMemberToolResult)
+			if !ok {
+				content = append(content, block)
+				continue
+			}
+			id := aws.ToString(result.Value.ToolUseId)
+			if !toolUses[id] {
+				return nil, fmt.Errorf("tool result %q does not match any tool request in the conversation", id)
+			}
+			// Keep the first result of a tool call
+			if _, dup := results[id]; !dup {
+				results[id] = result
+			}
+		}
+		if len(content) > 0 {
+			stripped = append(stripped, types.Message{Role: msg.Role, Content: content})
+		}
+	}
+
+	// Rebuild the conversation with the results right after the assistant message calling the
+	// tools, in call order
+	var rebuilt []types.Message
+	for _, msg := range mergeAdjacentMessages(stripped) {
+		rebuilt = append(rebuilt, msg)
+		if msg.Role != types.ConversationRoleAssistant {
+			continue
+		}
+		var resultBlocks []types.ContentBlock
+		for _, block := range msg.Content {
+			toolUse, ok := block.(*types.ContentBlockMemberToolUse)
+			if !ok {
+				continue
+			}
+			id := aws.ToString(toolUse.Value.ToolUseId)
+			if result, ok := results[id]; ok {
+				resultBlocks = append(resultBlocks, result)
+				continue
+			}
+			resultBlocks = append(resultBlocks, &types.ContentBlockMemberToolResult{
+				Value: types.ToolResultBlock{
+					ToolUseId: aws.String(id),
+					Content:   []types.ToolResultContentBlock{&types.ToolResultContentBlockMemberText{Value: placeholderToolResultMsg}},
+					Status:    types.ToolResultStatusError,
+				},
+			})
+		}
+		if len(resultBlocks) > 0 {
+			rebuilt = append(rebuilt, types.Message{Role: types.ConversationRoleUser, Content: resultBlocks})
+		}
+	}
+	// Cache points can't stand alone, so a message left with only cache points gives them to
+	// the previous message
+	var cleaned []types.Message
+	for _, msg := range mergeAdjacentMessages(rebuilt) {
+		if !onlyCachePoints(msg.Content) {
+			cleaned = append(cleaned, msg)
+		} else if n := len(cleaned); n > 0 {
+			cleaned[n-1].Content = append(cleaned[n-1].Content, msg.Content...)
+		}
+	}
+	normalized := mergeAdjacentMessages(cleaned)
+
+	if len(normalized) > 0 && normalized[0].Role != types.ConversationRoleUser {
+		placeholder := types.Message{
+			Role:    types.ConversationRoleUser,
+			Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: placeholderUserText}},
+		}
+		normalized = append([]types.Message{placeholder}, normalized...)
+	}
+	return normalized, nil
+}
+
+// mergeAdjacentMessages merges consecutive messages with the same role. Tool results are kept
+// at the start of a merged user message, as Converse requires.
+func mergeAdjacentMessages(messages []types.Message) []types.Message {
+	var merged []types.Message
+	for _, msg := range messages {
+		n := len(merged)
+		if n == 0 || merged[n-1].Role != msg.Role {
+			merged = append(merged, types.Message{Role: msg.Role, Content: slices.Clone(msg.Content)})
+			continue
+		}
+		content := append(merged[n-1].Content, msg.Content...)
+		if msg.Role == types.ConversationRoleUser {
+			slices.SortStableFunc(content, func(a, b types.ContentBlock) int {
+				return toolResultOrder(a) - toolResultOrder(b)
+			})
+		}
+		merged[n-1].Content = content
+	}
+	return merged
+}
+
+// toolResultOrder sorts tool results before other content blocks.
+func toolResultOrder(block types.ContentBlock) int {
+	if _, ok := block.(*types.ContentBlockMemberToolResult); ok {
+		return 0
+	}
+	return 1
+}
+
+// onlyCachePoints reports whether a message has cache points and nothing else.
+func onlyCachePoints(content []types.ContentBlock) bool {
+	for _, block := range content {
+		if _, ok := block.(*types.ContentBlockMemberCachePoint); !ok {
+			return false
+		}
+	}
+	return len(content) > 0
+}
@@ -0,0 +1,128 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+	"github.com/xavidop/genkit-aws-bedrock-go/bedrocktest"
+)
+
+func toolRequest(ref string) *ai.Part {
+	return ai.NewToolRequestPart(&ai.ToolRequest{Name: "getWeather", Ref: ref, Input: map[string]any{"city": "Paris"}})
+}
+
+func toolResponse(ref string) *ai.Message {
+	return ai.NewMessage(ai.RoleTool, nil, ai.NewToolResponsePart(&ai.ToolResponse{Name: "getWeather", Ref: ref, Output: "sunny"}))
+}
+
+// blockKinds describes the content blocks of a message, e.g. "text", "toolUse:t1" or "toolResult:t1:success".
+func blockKinds(msg types.Message) []string {
+	var kinds []string
+	for _, block := range msg.Content {
+		switch b := block.(type) {
+		case *types.ContentBlockMemberText:
+			kinds = append(kinds, "text")
+		case *types.ContentBlockMemberToolUse:
+			kinds = append(kinds, "toolUse:"+aws.ToString(b.Value.ToolUseId))
+		case *types.ContentBlockMemberToolResult:
+			kinds = append(kinds, "toolResult:"+aws.ToString(b.Value.ToolUseId)+":"+string(b.Value.Status))
+		default:
+			kinds = append(kinds, "other")
+		}
+	}
+	return kinds
+}
+
+func TestNormalizeMessages(t *testing.T) {
+	fake := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("ok"))
+	g, m := defineTestModel(t, fake, testModel)
+	tool := genkit.DefineTool(g, "getWeather", "Returns the weather", func(ctx *ai.ToolContext, input struct {
+		City string `json:"city"`
+	}) (string, error) {
+		return "sunny", nil
+	})
+
+	_, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithTools(tool),
+		ai.WithReturnToolRequests(true),
+		ai.WithMessages(
+			ai.NewModelTextMessage("Hi, how can I help?"),
+			ai.NewUserTextMessage("What's the weather"),
+			ai.NewUserTextMessage("in Paris and Lyon?"),
+			ai.NewModelMessage(toolRequest("t1"), toolRequest("t2")),
+			toolResponse("t2"),
+			ai.NewUserTextMessage("Also tomorrow."),
+			toolResponse("t1"),
+			ai.NewModelMessage(ai.NewTextPart("Checking tomorrow."), toolRequest("t3")),
+			ai.NewUserTextMessage("Never mind."),
+		),
+	)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	want := []struct {
+		role   types.ConversationRole
+		blocks string
+	}{
+		{types.ConversationRoleUser, "text"}, // Placeholder before the first assistant message
+		{types.ConversationRoleAssistant, "text"},
+		{types.ConversationRoleUser, "text text"},
+		{types.ConversationRoleAssistant, "toolUse:t1 toolUse:t2"},
+		{types.ConversationRoleUser, "toolResult:t1:success toolResult:t2:success text"},
+		{types.ConversationRoleAssistant, "text toolUse:t3"},
+		{types.ConversationRoleUser, "toolResult:t3:error text"}, // Missing result reported as failed
+	}
+	messages := fake.ConverseInputs()[0].Messages
+	if len(messages) != len(want) {
+		for _, msg := range messages {
+			t.Logf("%s %v", msg.Role, blockKinds(msg))
+		}
+		t.Fatalf("got %d messages, want %d", len(messages), len(want))
+	}
+	for i, w := range want {
+		if got := messages[i]; got.Role != w.role || strings.Join(blockKinds(got), " ") != w.blocks {
+			t.Errorf("message %d = %s %v, want %s [%s]", i, got.Role, blockKinds(got), w.role, w.blocks)
+		}
+	}
+}
+
+func TestNormalizeMessagesUnmatchedToolResult(t *testing.T) {
+	fake := bedrocktest.NewClient()
+	g, m := defineTestModel(t, fake, testModel)
+
+	_, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithMessages(ai.NewUserTextMessage("Hi"), toolResponse("unknown")),
+	)
+	if !errors.Is(err, bedrock.ErrValidation) {
+		t.Fatalf("error = %v, want ErrValidation", err)
+	}
+	if n := len(fake.ConverseInputs()); n != 0 {
+		t.Errorf("got %d Converse calls, want the request rejected before calling Bedrock", n)
+	}
+}
@@ -0,0 +1,289 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core"
+)
+
+// RateLimit configures client-side limits for a model, so workers sharing an account quota
+// stay under it instead of being throttled by Bedrock. Zero values mean no limit. Limits are
+// kept per model and shared by all tenants of the model.
+type RateLimit struct {
+	RequestsPerMinute int  // Maximum requests per minute
+	TokensPerMinute   int  // Maximum input + output tokens per minute, estimated before each call
+	MaxConcurrency    int  // Maximum in-flight requests
+	Queue             bool // Wait for capacity (until the context is done) instead of failing with ErrRateLimited
+}
+
+// rateLimiter enforces a RateLimit for one model.
+type rateLimiter struct {
+	logger *slog.Logger
+	queue  bool
+	sem    chan struct{} // In-flight slots, nil if concurrency is unlimited
+
+	mu       sync.Mutex
+	requests *bucket // nil if unlimited
+	tokens   *bucket // nil if unlimited
+}
+
+// bucket is a token bucket refilled continuously at capacity per minute.
+type bucket struct {
+	capacity  float64
+	available float64
+	last      time.Time
+}
+
+func newBucket(perMinute int) *bucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &bucket{capacity: float64(perMinute), available: float64(perMinute), last: time.Now()}
+}
+
+// refill adds the capacity accrued since the last refill.
+func (b *bucket) refill(now time.Time) {
+	b.available += now.Sub(b.last).Minutes() * b.capacity
+	if b.available > b.capacity {
+		b.available = b.capacity
+	}
+	b.last = now
+}
+
+// wait returns how long until n units are available. Requests larger than the bucket
+// only wait for it to be full.
+func (b *bucket) wait(n float64) time.Duration {
+	n = min(n, b.capacity)
+	if b.available >= n {
+		return 0
+	}
+	return time.Duration((n - b.available) / b.capacity * float64(time.Minute))
+}
+
+func newRateLimiter(limit *RateLimit, logger *slog.Logger) *rateLimiter {
+	if limit == nil {
+		return nil
+	}
+	l := &rateLimiter{
+		logger:   logger,
+		queue:    limit.Queue,
+		requests: newBucket(limit.RequestsPerMinute),
+		tokens:   newBucket(limit.TokensPerMinute),
+	}
+	if limit.MaxConcurrency > 0 {
+		l.sem = make(chan struct{}, limit.MaxConcurrency)
+	}
+	if l.sem == nil && l.requests == nil && l.tokens == nil {
+		return nil
+	}
+	return l
+}
+
+// acquire reserves capacity for a request estimated to use estimatedTokens. The returned
+// release function frees the concurrency slot and reconciles the token estimate against the
+// tokens actually used (pass a negative value if unknown).
+func (l *rateLimiter) acquire(ctx context.Context, modelID string, estimatedTokens int) (func(usedTokens int), error) {
+	if l.sem != nil {
+		if l.queue {
+			select {
+			case l.sem <- struct{}{}:
+			case <-ctx.Done():
+				return nil, newError("Converse", modelID, ctx.Err())
+			}
+		} else {
+			select {
+			case l.sem <- struct{}{}:
+			default:
+				return nil, newRateLimitError(modelID, "too many concurrent requests")
+			}
+		}
+	}
+	releaseSlot := func() {
+		if l.sem != nil {
+			<-l.sem
+		}
+	}
+
+	var reserved float64 // Tokens taken from the bucket, at most its capacity
+	for {
+		var wait time.Duration
+		reserved, wait = l.tryReserve(float64(estimatedTokens))
+		if wait == 0 {
+			break
+		}
+		if !l.queue {
+			releaseSlot()
+			return nil, newRateLimitError(modelID, fmt.Sprintf("capacity available in %s", wait.Round(time.Millisecond)))
+		}
+		l.logger.DebugContext(ctx, "bedrock: waiting for client-side rate limit", "model", modelID, "wait", wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			releaseSlot()
+			return nil, newError("Converse", modelID, ctx.Err())
+		}
+	}
+
+	var once sync.Once
+	return func(usedTokens int) {
+		once.Do(func() {
+			releaseSlot()
+			if l.tokens != nil && usedTokens >= 0 {
+				l.mu.Lock()
+				l.tokens.available = min(l.tokens.available+reserved-float64(usedTokens), l.tokens.capacity)
+				l.mu.Unlock()
+			}
+		})
+	}, nil
+}
+
+// tryReserve takes one request and the estimated tokens, capped at the bucket capacity, if
+// both are available and returns the tokens taken. Otherwise it returns how long to wait
+// before trying again.
+func (l *rateLimiter) tryReserve(tokens float64) (float64, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	if l.requests != nil {
+		l.requests.refill(now)
+		wait = max(wait, l.requests.wait(1))
+	}
+	if l.tokens != nil {
+		l.tokens.refill(now)
+		wait = max(wait, l.tokens.wait(tokens))
+	}
+	if wait > 0 {
+		return 0, wait
+	}
+
+	if l.requests != nil {
+		l.requests.available--
+	}
+	var reserved float64
+	if l.tokens != nil {
+		reserved = min(tokens, l.tokens.capacity)
+		l.tokens.available -= reserved
+	}
+	return reserved, 0
+}
+
+// limiterFor returns the rate limiter of a model, creating it from the model definition or the
+// plugin default on first use. It returns nil if the model is not rate limited.
+func (b *Bedrock) limiterFor(modelID string) *rateLimiter {
+	b.limitMu.Lock()
+	defer b.limitMu.Unlock()
+
+	if l, ok := b.limiters[modelID]; ok {
+		return l
+	}
+	if b.limiters == nil {
+		b.limiters = make(map[string]*rateLimiter)
+	}
+	l := newRateLimiter(b.RateLimit, b.logger())
+	b.limiters[modelID] = l
+	return l
+}
+
+// setModelRateLimit configures the rate limiter of a model defined with its own RateLimit.
+func (b *Bedrock) setModelRateLimit(modelID string, limit *RateLimit) {
+	b.limitMu.Lock()
+	defer b.limitMu.Unlock()
+
+	if b.limiters == nil {
+		b.limiters = make(map[string]*rateLimiter)
+	}
+	b.limiters[modelID] = newRateLimiter(limit, b.logger())
+}
+
+// estimateTokens roughly estimates the tokens a request will use: its input tokens and the
+// requested maximum output tokens.
+func estimateTokens(input *ai.ModelRequest) int {
+	tokens := estimateInputTokens(input)
+	if configMap, ok := input.Config.(map[string]interface{}); ok {
+		if maxTokens, ok := configMap["maxOutputTokens"].(int); ok {
+			tokens += maxTokens
+		} else if maxTokens, ok := configMap["max_tokens"].(int); ok {
+			tokens += maxTokens
+		}
+	}
+	return tokens
+}
+
+// estimateInputTokens roughly estimates the input tokens of a request: about four characters
+// per token for text, tool calls and tool definitions, and a fixed cost per media part.
+func estimateInputTokens(input *ai.ModelRequest) int {
+	const charsPerToken = 4
+	const tokensPerMedia = 1600
+
+	chars, tokens := 0, 0
+	for _, msg := range input.Messages {
+		for _, part := range msg.Content {
+			switch {
+			case part.IsText(), part.IsReasoning():
+				chars += len(part.Text)
+			case part.IsMedia():
+				tokens += tokensPerMedia
+			case part.IsToolRequest() && part.ToolRequest != nil:
+				chars += len(part.ToolRequest.Name) + jsonLen(part.ToolRequest.Input)
+			case part.IsToolResponse() && part.ToolResponse != nil:
+				chars += jsonLen(part.ToolResponse.Output)
+			}
+		}
+	}
+	for _, tool := range input.Tools {
+		chars += len(tool.Name) + len(tool.Description) + jsonLen(tool.InputSchema)
+	}
+	return tokens + (chars+charsPerToken-1)/charsPerToken
+}
+
+// jsonLen returns the length of v encoded as JSON, or 0 if it cannot be encoded.
+func jsonLen(v any) int {
+	if v == nil {
+		return 0
+	}
+	if s, ok := v.(string); ok {
+		return len(s)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// newRateLimitError reports a request rejected by a client-side rate limit.
+func newRateLimitError(modelID, msg string) *Error {
+	return &Error{
+		Kind:      ErrRateLimited,
+		Status:    core.RESOURCE_EXHAUSTED,
+		Operation: "Converse",
+		ModelID:   modelID,
+		Err:       fmt.Errorf("%s", msg),
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+	"github.com/xavidop/genkit-aws-bedrock-go/bedrocktest"
+)
+
+func TestRateLimit(t *testing.T) {
+	fake := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("ok"))
+	b := &bedrock.Bedrock{Client: fake}
+	g := newTestPlugin(t, b)
+	m := b.DefineModel(g, bedrock.ModelDefinition{
+		Name:      testModel,
+		Type:      "chat",
+		RateLimit: &bedrock.RateLimit{RequestsPerMinute: 1},
+	}, nil)
+
+	if _, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("hi")); err != nil {
+		t.Fatalf("first Generate: %v", err)
+	}
+
+	_, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("hi"))
+	if !errors.Is(err, bedrock.ErrRateLimited) {
+		t.Fatalf("second Generate error = %v, want ErrRateLimited", err)
+	}
+	if n := len(fake.ConverseInputs()); n != 1 {
+		t.Errorf("got %d Converse calls, want 1", n)
+	}
+}
+
+func TestRateLimitEstimateAboveCapacity(t *testing.T) {
+	fake := bedrocktest.NewClient().
+		AddConverse(bedrocktest.TextOutput("ok")).
+		AddConverse(bedrocktest.TextOutput("ok"))
+	b := &bedrock.Bedrock{Client: fake}
+	g := newTestPlugin(t, b)
+	m := b.DefineModel(g, bedrock.ModelDefinition{
+		Name:      testModel,
+		Type:      "chat",
+		RateLimit: &bedrock.RateLimit{TokensPerMinute: 100},
+	}, nil)
+
+	// The estimate is capped at the capacity, and only the unused part of that is refunded
+	large := ai.WithConfig(map[string]any{"maxOutputTokens": 1000})
+	if _, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("hi"), large); err != nil {
+		t.Fatalf("first Generate: %v", err)
+	}
+
+	// 15 tokens were used, so 85 are left for a request estimated at 91
+	small := ai.WithConfig(map[string]any{"maxOutputTokens": 90})
+	_, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("hi"), small)
+	if !errors.Is(err, bedrock.ErrRateLimited) {
+		t.Fatalf("second Generate error = %v, want ErrRateLimited", err)
+	}
+	if n := len(fake.ConverseInputs()); n != 1 {
+		t.Errorf("got %d Converse calls, want 1", n)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Region selection strategies for text models.
+const (
+	RegionStrategyOrdered  = "ordered"  // Use regions in order, failing over to the next one
+	RegionStrategyWeighted = "weighted" // Spread requests across regions by weight, failing over to the others
+)
+
+// RegionConfig configures a region used by text models for failover and load spreading.
+type RegionConfig struct {
+	Region       string        // AWS region, e.g. "eu-central-1"
+	Weight       int           // Relative weight for RegionStrategyWeighted (default: 1)
+	Client       BedrockClient // Runtime client for this region (default: created from the AWS config)
+	BaseEndpoint string        // Runtime endpoint for this region (default: Bedrock.BaseEndpoint)
+}
+
+// regionClient is a Bedrock Runtime client for one region with its health state.
+type regionClient struct {
+	region string
+	weight int
+	client BedrockClient
+
+	mu             sync.Mutex
+	unhealthyUntil time.Time // Region is skipped until this time after a failure
+}
+
+// healthy reports whether the region is outside its cooldown.
+func (r *regionClient) healthy(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !now.Before(r.unhealthyUntil)
+}
+
+// regionPool selects the region used for each text model request.
+type regionPool struct {
+	strategy string
+	cooldown time.Duration
+	regions  []*regionClient
+}
+
+// candidates returns the regions to try in order. Healthy regions come first, ordered or
+// shuffled by weight depending on the strategy; regions in cooldown are kept as a last resort.
+func (p *regionPool) candidates() []*regionClient {
+	now := time.Now()
+	var healthy, unhealthy []*regionClient
+	for _, r := range p.regions {
+		if r.healthy(now) {
+			healthy = append(healthy, r)
+		} else {
+			unhealthy = append(unhealthy, r)
+		}
+	}
+
+	if p.strategy == RegionStrategyWeighted {
+		healthy = weightedShuffle(healthy)
+	}
+
+	// Retry the regions whose cooldown ends first
+	slices.SortStableFunc(unhealthy, func(a, b *regionClient) int {
+		return a.unhealthyUntilTime().Compare(b.unhealthyUntilTime())
+	})
+	return append(healthy, unhealthy...)
+}
+
+// unhealthyUntilTime returns the end of the region cooldown.
+func (r *regionClient) unhealthyUntilTime() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.unhealthyUntil
+}
+
+// markFailure puts the region in cooldown.
+func (p *regionPool) markFailure(r *regionClient) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.unhealthyUntil = time.Now().Add(p.cooldown)
+}
+
+// markSuccess clears the region cooldown.
+func (p *regionPool) markSuccess(r *regionClient) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.unhealthyUntil = time.Time{}
+}
+
+// weightedShuffle orders regions by weighted random sampling without replacement.
+func weightedShuffle(regions []*regionClient) []*regionClient {
+	remaining := slices.Clone(regions)
+	ordered := make([]*regionClient, 0, len(regions))
+	for len(remaining) > 0 {
+		total := 0
+		for _, r := range remaining {
+			total += r.weight
+		}
+		pick := rand.IntN(total)
+		for i, r := range remaining {
+			if pick < r.weight {
+				ordered = append(ordered, r)
+				remaining = slices.Delete(remaining, i, i+1)
+				break
+			}
+			pick -= r.weight
+		}
+	}
+	return ordered
+}
+
+// isFailoverError reports whether a failed call should be retried in another region.
+func isFailoverError(err error) bool {
+	return errors.Is(err, ErrThrottled) ||
+		errors.Is(err, ErrQuotaExceeded) ||
+		errors.Is(err, ErrServiceUnavailable) ||
+		errors.Is(err, ErrModelNotReady) ||
+		errors.Is(err, ErrModelTimeout)
+}
+
+// withRegionFailover calls fn with each candidate region until it succeeds, fails with an
+// error that is not specific to the region, or the context is done.
+func withRegionFailover[T any](ctx context.Context, p *regionPool, logger *slog.Logger, fn func(*regionClient) (T, error)) (T, *regionClient, error) {
+	var zero T
+	var lastErr error
+	for _, r := range p.candidates() {
+		result, err := fn(r)
+		if err == nil {
+			p.markSuccess(r)
+			return result, r, nil
+		}
+
+		var be *Error
+		if errors.As(err, &be) {
+			be.Region = r.region
+		}
+		lastErr = err
+
+		if !isFailoverError(err) || ctx.Err() != nil {
+			return zero, r, err
+		}
+		p.markFailure(r)
+		logger.WarnContext(ctx, "bedrock: region failed, trying next region", "region", r.region, "cooldown", p.cooldown, "error", err)
+	}
+	return zero, nil, lastErr
+}
@@ -0,0 +1,95 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+	"github.com/xavidop/genkit-aws-bedrock-go/bedrocktest"
+)
+
+// defineMultiRegionModel returns a text model served by one fake client per region.
+func defineMultiRegionModel(t *testing.T, clients map[string]*bedrocktest.Client, regions ...string) (*genkit.Genkit, ai.Model) {
+	t.Helper()
+	b := &bedrock.Bedrock{}
+	for _, region := range regions {
+		b.Regions = append(b.Regions, bedrock.RegionConfig{Region: region, Client: clients[region]})
+	}
+	g := newTestPlugin(t, b)
+	return g, b.DefineModel(g, bedrock.ModelDefinition{Name: testModel, Type: "chat"}, nil)
+}
+
+func TestRegionFailover(t *testing.T) {
+	throttled := &types.ThrottlingException{Message: aws.String("slow down")}
+	clients := map[string]*bedrocktest.Client{
+		"us-east-1": bedrocktest.NewClient().AddConverseError(throttled),
+		"us-west-2": bedrocktest.NewClient().
+			AddConverse(bedrocktest.TextOutput("from us-west-2")).
+			AddConverse(bedrocktest.TextOutput("still us-west-2")),
+	}
+	g, m := defineMultiRegionModel(t, clients, "us-east-1", "us-west-2")
+
+	resp, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("hi"))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if got := resp.Text(); got != "from us-west-2" {
+		t.Errorf("Text() = %q, want the us-west-2 response", got)
+	}
+	if got := resp.Custom.(map[string]any)["region"]; got != "us-west-2" {
+		t.Errorf("Custom[region] = %v, want us-west-2", got)
+	}
+
+	// The throttled region is in cooldown, so the next request goes straight to us-west-2
+	if _, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("hi")); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if n := len(clients["us-east-1"].ConverseInputs()); n != 1 {
+		t.Errorf("us-east-1 got %d calls, want 1", n)
+	}
+	if n := len(clients["us-west-2"].ConverseInputs()); n != 2 {
+		t.Errorf("us-west-2 got %d calls, want 2", n)
+	}
+}
+
+func TestRegionFailoverStopsOnClientErrors(t *testing.T) {
+	invalid := &types.ValidationException{Message: aws.String("bad request")}
+	clients := map[string]*bedrocktest.Client{
+		"us-east-1": bedrocktest.NewClient().AddConverseError(invalid),
+		"us-west-2": bedrocktest.NewClient(),
+	}
+	g, m := defineMultiRegionModel(t, clients, "us-east-1", "us-west-2")
+
+	_, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("hi"))
+	if !errors.Is(err, bedrock.ErrValidation) {
+		t.Fatalf("error = %v, want ErrValidation", err)
+	}
+	var be *bedrock.Error
+	if errors.As(err, &be) && be.Region != "us-east-1" {
+		t.Errorf("Region = %q, want us-east-1", be.Region)
+	}
+	if n := len(clients["us-west-2"].ConverseInputs()); n != 0 {
+		t.Errorf("us-west-2 got %d calls, want none", n)
+	}
+}
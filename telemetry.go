@@ -0,0 +1,322 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/smithy-go/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the plugin tracer and meter.
+const instrumentationName = "github.com/xavidop/genkit-aws-bedrock-go"
+
+// GenAI semantic convention values used by the plugin.
+const (
+	genAISystem = "aws.bedrock"
+
+	genAIOperationChat       = "chat"
+	genAIOperationEmbeddings = "embeddings"
+	genAIOperationGenerate   = "generate_content"
+)
+
+// telemetry holds the tracer, metric instruments and logger of the plugin.
+type telemetry struct {
+	tracer         trace.Tracer
+	logger         *slog.Logger
+	captureContent bool
+
+	operationDuration metric.Float64Histogram
+	tokenUsage        metric.Int64Histogram
+	timeToFirstChunk  metric.Float64Histogram
+	timePerChunk      metric.Float64Histogram
+	errors            metric.Int64Counter
+}
+
+// newTelemetry creates the plugin instrumentation, using the global OpenTelemetry providers
+// when tp or mp is nil.
+func newTelemetry(tp trace.TracerProvider, mp metric.MeterProvider, captureContent bool, logger *slog.Logger) *telemetry {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	meter := mp.Meter(instrumentationName)
+
+	t := &telemetry{
+		tracer:         tp.Tracer(instrumentationName),
+		logger:         logger,
+		captureContent: captureContent,
+	}
+
+	// Instrument creation only fails for invalid names; fall back to no-op instruments
+	var err error
+	if t.operationDuration, err = meter.Float64Histogram("gen_ai.client.operation.duration",
+		metric.WithDescription("GenAI operation duration"), metric.WithUnit("s")); err != nil {
+		otel.Handle(err)
+	}
+	if t.tokenUsage, err = meter.Int64Histogram("gen_ai.client.token.usage",
+		metric.WithDescription("Measures number of input and output tokens used"), metric.WithUnit("{token}")); err != nil {
+		otel.Handle(err)
+	}
+	if t.timeToFirstChunk, err = meter.Float64Histogram("gen_ai.client.operation.time_to_first_chunk",
+		metric.WithDescription("Time to receive the first chunk of a streaming response"), metric.WithUnit("s")); err != nil {
+		otel.Handle(err)
+	}
+	if t.timePerChunk, err = meter.Float64Histogram("gen_ai.client.operation.time_per_output_chunk",
+		metric.WithDescription("Time between consecutive chunks of a streaming response"), metric.WithUnit("s")); err != nil {
+		otel.Handle(err)
+	}
+	if t.errors, err = meter.Int64Counter("aws.bedrock.client.errors",
+		metric.WithDescription("Number of failed Bedrock API calls"), metric.WithUnit("{error}")); err != nil {
+		otel.Handle(err)
+	}
+	return t
+}
+
+// callSpan tracks one Bedrock API call.
+type callSpan struct {
+	t         *telemetry
+	span      trace.Span
+	operation string
+	modelID   string
+	region    string
+	start     time.Time
+	lastChunk time.Time
+	attrs     []attribute.KeyValue // Metric attributes
+}
+
+// startCall starts a client span named "<operation> <model>" for a Bedrock API call.
+func (t *telemetry) startCall(ctx context.Context, operation, modelID, region string) (context.Context, *callSpan) {
+	attrs := []attribute.KeyValue{
+		attribute.String("gen_ai.system", genAISystem),
+		attribute.String("gen_ai.provider.name", genAISystem),
+		attribute.String("gen_ai.operation.name", operation),
+		attribute.String("gen_ai.request.model", modelID),
+	}
+	if region != "" {
+		attrs = append(attrs, attribute.String("cloud.region", region))
+	}
+
+	ctx, span := t.tracer.Start(ctx, operation+" "+modelID,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	return ctx, &callSpan{
+		t:         t,
+		span:      span,
+		operation: operation,
+		modelID:   modelID,
+		region:    region,
+		start:     time.Now(),
+		attrs:     attrs,
+	}
+}
+
+// setRequest records the inference parameters and, if enabled, the prompt of a Converse request.
+func (c *callSpan) setRequest(config *types.InferenceConfiguration, system []types.SystemContentBlock, messages []types.Message) {
+	if config != nil {
+		if config.MaxTokens != nil {
+			c.span.SetAttributes(attribute.Int("gen_ai.request.max_tokens", int(*config.MaxTokens)))
+		}
+		if config.Temperature != nil {
+			c.span.SetAttributes(attribute.Float64("gen_ai.request.temperature", float64(*config.Temperature)))
+		}
+		if config.TopP != nil {
+			c.span.SetAttributes(attribute.Float64("gen_ai.request.top_p", float64(*config.TopP)))
+		}
+		if len(config.StopSequences) > 0 {
+			c.span.SetAttributes(attribute.StringSlice("gen_ai.request.stop_sequences", config.StopSequences))
+		}
+	}
+	if c.t.captureContent {
+		if len(system) > 0 {
+			var parts []map[string]any
+			for _, block := range system {
+				if text, ok := block.(*types.SystemContentBlockMemberText); ok {
+					parts = append(parts, map[string]any{"type": "text", "content": text.Value})
+				}
+			}
+			c.span.SetAttributes(attribute.String("gen_ai.system_instructions", contentJSON(parts)))
+		}
+		var msgs []map[string]any
+		for _, msg := range messages {
+			msgs = append(msgs, map[string]any{"role": string(msg.Role), "parts": contentParts(msg.Content)})
+		}
+		c.span.SetAttributes(attribute.String("gen_ai.input.messages", contentJSON(msgs)))
+	}
+}
+
+// chunk records the latency of a streamed chunk.
+func (c *callSpan) chunk(ctx context.Context) {
+	now := time.Now()
+	if c.lastChunk.IsZero() {
+		c.t.timeToFirstChunk.Record(ctx, now.Sub(c.start).Seconds(), metric.WithAttributes(c.attrs...))
+		c.span.AddEvent("gen_ai.first_chunk")
+	} else {
+		c.t.timePerChunk.Record(ctx, now.Sub(c.lastChunk).Seconds(), metric.WithAttributes(c.attrs...))
+	}
+	c.lastChunk = now
+}
+
+// callResult describes the outcome of a Bedrock API call.
+type callResult struct {
+	StopReason types.StopReason
+	Usage      *types.TokenUsage
+	Output     string // Completion text, recorded only if content capture is enabled
+	Metadata   middleware.Metadata
+	Err        error
+}
+
+// end records the outcome of the call, logs it and ends the span.
+func (c *callSpan) end(ctx context.Context, result callResult) {
+	defer c.span.End()
+
+	latency := time.Since(c.start)
+	logAttrs := []any{
+		"operation", c.operation,
+		"model", c.modelID,
+		"region", c.region,
+		"latency", latency,
+	}
+
+	if requestID, ok := awsmiddleware.GetRequestIDMetadata(result.Metadata); ok {
+		c.span.SetAttributes(attribute.String("aws.request_id", requestID))
+		logAttrs = append(logAttrs, "requestId", requestID)
+	}
+	if result.StopReason != "" {
+		c.span.SetAttributes(attribute.StringSlice("gen_ai.response.finish_reasons", []string{string(result.StopReason)}))
+	}
+	if c.t.captureContent && result.Output != "" {
+		c.span.SetAttributes(attribute.String("gen_ai.output.messages", contentJSON([]map[string]any{{
+			"role":          "assistant",
+			"parts":         []map[string]any{{"type": "text", "content": result.Output}},
+			"finish_reason": string(result.StopReason),
+		}})))
+	}
+
+	attrs := c.attrs
+	if result.Err != nil {
+		errorType := "_OTHER"
+		var be *Error
+		if errors.As(result.Err, &be) {
+			if be.Code != "" {
+				errorType = be.Code
+			} else {
+				errorType = string(be.Status)
+			}
+			if be.RequestID != "" {
+				c.span.SetAttributes(attribute.String("aws.request_id", be.RequestID))
+				logAttrs = append(logAttrs, "requestId", be.RequestID)
+			}
+		}
+		attrs = append(attrs, attribute.String("error.type", errorType))
+		c.span.SetAttributes(attribute.String("error.type", errorType))
+		c.span.RecordError(result.Err)
+		c.span.SetStatus(codes.Error, result.Err.Error())
+		c.t.errors.Add(ctx, 1, metric.WithAttributes(attrs...))
+
+		// Throttling and outages are expected and usually retried; other failures are errors
+		level := slog.LevelError
+		if isFailoverError(result.Err) || errors.Is(result.Err, ErrCanceled) {
+			level = slog.LevelWarn
+		}
+		c.t.logger.Log(ctx, level, "bedrock: call failed", append(logAttrs, "errorType", errorType, "error", result.Err)...)
+	}
+
+	if usage := result.Usage; usage != nil {
+		input, output := int64(aws.ToInt32(usage.InputTokens)), int64(aws.ToInt32(usage.OutputTokens))
+		c.span.SetAttributes(
+			attribute.Int64("gen_ai.usage.input_tokens", input),
+			attribute.Int64("gen_ai.usage.output_tokens", output),
+			attribute.Int64("gen_ai.usage.cache_read.input_tokens", int64(aws.ToInt32(usage.CacheReadInputTokens))),
+			attribute.Int64("gen_ai.usage.cache_creation.input_tokens", int64(aws.ToInt32(usage.CacheWriteInputTokens))),
+		)
+		c.t.tokenUsage.Record(ctx, input, metric.WithAttributes(append(attrs, attribute.String("gen_ai.token.type", "input"))...))
+		c.t.tokenUsage.Record(ctx, output, metric.WithAttributes(append(attrs, attribute.String("gen_ai.token.type", "output"))...))
+	}
+
+	c.t.operationDuration.Record(ctx, latency.Seconds(), metric.WithAttributes(attrs...))
+
+	if result.Err == nil {
+		if usage := result.Usage; usage != nil {
+			logAttrs = append(logAttrs,
+				"inputTokens", aws.ToInt32(usage.InputTokens),
+				"outputTokens", aws.ToInt32(usage.OutputTokens),
+				"cacheReadInputTokens", aws.ToInt32(usage.CacheReadInputTokens),
+			)
+		}
+		if result.StopReason != "" {
+			logAttrs = append(logAttrs, "stopReason", result.StopReason)
+		}
+		c.t.logger.DebugContext(ctx, "bedrock: call completed", logAttrs...)
+	}
+}
+
+// contentParts converts Converse content blocks to GenAI semantic convention message parts.
+// Binary content is omitted.
+func contentParts(blocks []types.ContentBlock) []map[string]any {
+	var parts []map[string]any
+	for _, block := range blocks {
+		switch b := block.(type) {
+		case *types.ContentBlockMemberText:
+			parts = append(parts, map[string]any{"type": "text", "content": b.Value})
+		case *types.ContentBlockMemberToolUse:
+			part := map[string]any{"type": "tool_call", "id": aws.ToString(b.Value.ToolUseId), "name": aws.ToString(b.Value.Name)}
+			if b.Value.Input != nil {
+				var args any
+				if err := b.Value.Input.UnmarshalSmithyDocument(&args); err == nil {
+					part["arguments"] = args
+				}
+			}
+			parts = append(parts, part)
+		case *types.ContentBlockMemberToolResult:
+			var response []string
+			for _, c := range b.Value.Content {
+				if text, ok := c.(*types.ToolResultContentBlockMemberText); ok {
+					response = append(response, text.Value)
+				}
+			}
+			parts = append(parts, map[string]any{"type": "tool_call_response", "id": aws.ToString(b.Value.ToolUseId), "response": response})
+		case *types.ContentBlockMemberImage:
+			parts = append(parts, map[string]any{"type": "blob", "modality": "image", "mime_type": "image/" + string(b.Value.Format)})
+		}
+	}
+	return parts
+}
+
+// contentJSON encodes content for content capture.
+func contentJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
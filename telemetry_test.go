@@ -0,0 +1,134 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+	"github.com/xavidop/genkit-aws-bedrock-go/bedrocktest"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// defineTracedModel returns a text model backed by the fake client whose spans are recorded.
+func defineTracedModel(t *testing.T, fake *bedrocktest.Client) (*genkit.Genkit, ai.Model, *tracetest.SpanRecorder) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	b := &bedrock.Bedrock{
+		Client:         fake,
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+	}
+	g := newTestPlugin(t, b)
+	return g, b.DefineModel(g, bedrock.ModelDefinition{Name: testModel, Type: "chat"}, nil), recorder
+}
+
+// bedrockSpan returns the single span recorded for a Bedrock API call.
+func bedrockSpan(t *testing.T, recorder *tracetest.SpanRecorder) sdktrace.ReadOnlySpan {
+	t.Helper()
+	var spans []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if hasAttribute(span, "gen_ai.system") {
+			spans = append(spans, span)
+		}
+	}
+	if len(spans) != 1 {
+		t.Fatalf("recorded %d Bedrock spans, want 1", len(spans))
+	}
+	return spans[0]
+}
+
+func hasAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) bool {
+	_, ok := spanAttribute(span, key)
+	return ok
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestTelemetrySpan(t *testing.T) {
+	fake := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("Hello!"))
+	g, m, recorder := defineTracedModel(t, fake)
+
+	_, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithPrompt("hi"),
+		ai.WithConfig(map[string]any{"maxOutputTokens": 100}),
+	)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	span := bedrockSpan(t, recorder)
+	if got, want := span.Name(), "chat "+testModel; got != want {
+		t.Errorf("span name = %q, want %q", got, want)
+	}
+	want := map[attribute.Key]attribute.Value{
+		"gen_ai.system":                  attribute.StringValue("aws.bedrock"),
+		"gen_ai.operation.name":          attribute.StringValue("chat"),
+		"gen_ai.request.model":           attribute.StringValue(testModel),
+		"gen_ai.request.max_tokens":      attribute.IntValue(100),
+		"cloud.region":                   attribute.StringValue("us-east-1"),
+		"gen_ai.usage.input_tokens":      attribute.Int64Value(10),
+		"gen_ai.usage.output_tokens":     attribute.Int64Value(5),
+		"gen_ai.response.finish_reasons": attribute.StringSliceValue([]string{"end_turn"}),
+	}
+	for key, value := range want {
+		got, ok := spanAttribute(span, key)
+		if !ok {
+			t.Errorf("span attribute %s missing", key)
+		} else if got != value {
+			t.Errorf("span attribute %s = %v, want %v", key, got.Emit(), value.Emit())
+		}
+	}
+	if hasAttribute(span, "gen_ai.input.messages") {
+		t.Error("span records prompts although CaptureContent is off")
+	}
+}
+
+func TestTelemetrySpanError(t *testing.T) {
+	throttled := &types.ThrottlingException{Message: aws.String("slow down")}
+	fake := bedrocktest.NewClient().AddConverseError(bedrocktest.ResponseError(429, "req-123", throttled))
+	g, m, recorder := defineTracedModel(t, fake)
+
+	if _, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithPrompt("hi")); err == nil {
+		t.Fatal("Generate succeeded, want a throttling error")
+	}
+
+	span := bedrockSpan(t, recorder)
+	if got, _ := spanAttribute(span, "error.type"); got.AsString() != "ThrottlingException" {
+		t.Errorf("error.type = %q, want ThrottlingException", got.AsString())
+	}
+	if got, _ := spanAttribute(span, "aws.request_id"); got.AsString() != "req-123" {
+		t.Errorf("aws.request_id = %q, want req-123", got.AsString())
+	}
+	if got := span.Status().Code.String(); got != "Error" {
+		t.Errorf("span status = %s, want Error", got)
+	}
+}
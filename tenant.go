@@ -0,0 +1,200 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	awsbedrock "github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core"
+)
+
+// TenantCredentials selects the AWS credentials a request runs under, so a single model can
+// serve many tenants. Either Credentials or RoleARN must be set.
+type TenantCredentials struct {
+	Credentials aws.CredentialsProvider // Credentials used as is, ideally wrapped in aws.NewCredentialsCache (optional)
+	RoleARN     string                  // Role assumed with the plugin's credentials (optional)
+	ExternalID  string                  // External ID required by the role's trust policy (optional)
+	SessionName string                  // Role session name (default: "genkit-bedrock")
+	Duration    time.Duration           // Role session duration (default: 1h)
+}
+
+type tenantContextKey struct{}
+
+// WithTenantCredentials returns a context whose Bedrock calls run under the tenant credentials.
+func WithTenantCredentials(ctx context.Context, creds TenantCredentials) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, creds)
+}
+
+// TenantCredentialsFromContext returns the tenant credentials set with WithTenantCredentials.
+func TenantCredentialsFromContext(ctx context.Context) (TenantCredentials, bool) {
+	creds, ok := ctx.Value(tenantContextKey{}).(TenantCredentials)
+	return creds, ok
+}
+
+// withRequestTenant returns a context carrying the role set in the request config with the
+// "roleArn" and "externalId" keys. Credentials already in the context take precedence. Since
+// request configs may come from untrusted callers, only roles allowed by RequestRoleARNs can
+// be assumed this way.
+func (b *Bedrock) withRequestTenant(ctx context.Context, modelID string, input *ai.ModelRequest) (context.Context, error) {
+	if _, ok := TenantCredentialsFromContext(ctx); ok {
+		return ctx, nil
+	}
+	configMap, ok := input.Config.(map[string]interface{})
+	if !ok {
+		return ctx, nil
+	}
+	roleARN, _ := configMap["roleArn"].(string)
+	if roleARN == "" {
+		return ctx, nil
+	}
+	if !b.requestRoleAllowed(roleARN) {
+		return nil, &Error{
+			Kind:      ErrAccessDenied,
+			Status:    core.PERMISSION_DENIED,
+			Operation: "AssumeRole",
+			ModelID:   modelID,
+			Err:       fmt.Errorf("role %s in the request config is not in RequestRoleARNs", roleARN),
+		}
+	}
+	externalID, _ := configMap["externalId"].(string)
+	return WithTenantCredentials(ctx, TenantCredentials{RoleARN: roleARN, ExternalID: externalID}), nil
+}
+
+// requestRoleAllowed reports whether RequestRoleARNs allows a role ARN, either exactly or by a
+// pattern ending with "*".
+func (b *Bedrock) requestRoleAllowed(roleARN string) bool {
+	for _, allowed := range b.RequestRoleARNs {
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok {
+			if strings.HasPrefix(roleARN, prefix) {
+				return true
+			}
+		} else if roleARN == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// tenantCache holds the assumed role credentials of each tenant. Each entry refreshes its
+// credentials shortly before they expire, and entries unused for the TTL are dropped.
+type tenantCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*tenantEntry
+}
+
+type tenantEntry struct {
+	provider *aws.CredentialsCache
+	lastUsed time.Time
+}
+
+// tenantCredentials returns the credentials provider for the tenant in the context, or nil
+// when the request uses the plugin's credentials.
+func (b *Bedrock) tenantCredentials(ctx context.Context) (aws.CredentialsProvider, error) {
+	creds, ok := TenantCredentialsFromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	if b.Anonymous {
+		return nil, errors.New("bedrock: tenant credentials cannot be used with Anonymous")
+	}
+	if creds.Credentials != nil {
+		return creds.Credentials, nil
+	}
+	if creds.RoleARN == "" {
+		return nil, errors.New("bedrock: tenant credentials need Credentials or RoleARN")
+	}
+	if creds.SessionName == "" {
+		creds.SessionName = "genkit-bedrock"
+	}
+	if creds.Duration == 0 {
+		creds.Duration = time.Hour
+	}
+
+	key := strings.Join([]string{creds.RoleARN, creds.ExternalID, creds.SessionName, creds.Duration.String()}, "|")
+	now := time.Now()
+
+	c := b.tenants
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[key]; ok {
+		entry.lastUsed = now
+		return entry.provider, nil
+	}
+
+	// Drop tenants that haven't been used for a while before adding a new one
+	for k, entry := range c.entries {
+		if now.Sub(entry.lastUsed) > c.ttl {
+			delete(c.entries, k)
+		}
+	}
+
+	provider := aws.NewCredentialsCache(
+		stscreds.NewAssumeRoleProvider(b.stsClient, creds.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			if creds.ExternalID != "" {
+				o.ExternalID = aws.String(creds.ExternalID)
+			}
+			o.RoleSessionName = creds.SessionName
+			o.Duration = creds.Duration
+		}),
+		func(o *aws.CredentialsCacheOptions) {
+			o.ExpiryWindow = 5 * time.Minute
+		},
+	)
+	if c.entries == nil {
+		c.entries = make(map[string]*tenantEntry)
+	}
+	c.entries[key] = &tenantEntry{provider: provider, lastUsed: now}
+	return provider, nil
+}
+
+// runtimeCallOptions returns the per-call Bedrock Runtime options for the tenant in the context.
+// Tenant credentials are always used to sign requests, even when an API key is configured.
+func (b *Bedrock) runtimeCallOptions(ctx context.Context) ([]func(*bedrockruntime.Options), error) {
+	provider, err := b.tenantCredentials(ctx)
+	if err != nil || provider == nil {
+		return nil, err
+	}
+	return []func(*bedrockruntime.Options){func(o *bedrockruntime.Options) {
+		o.Credentials = provider
+		o.AuthSchemePreference = []string{sigV4AuthScheme}
+	}}, nil
+}
+
+// controlCallOptions returns the per-call Bedrock control plane options for the tenant in the context.
+func (b *Bedrock) controlCallOptions(ctx context.Context) ([]func(*awsbedrock.Options), error) {
+	provider, err := b.tenantCredentials(ctx)
+	if err != nil || provider == nil {
+		return nil, err
+	}
+	return []func(*awsbedrock.Options){func(o *awsbedrock.Options) {
+		o.Credentials = provider
+		o.AuthSchemePreference = []string{sigV4AuthScheme}
+	}}, nil
+}
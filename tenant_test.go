@@ -0,0 +1,166 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+)
+
+// tenantServer answers STS AssumeRole and Bedrock Converse requests. Assumed roles get the
+// access key "KEY-<external ID>", and the access key that signed each Converse call is recorded.
+type tenantServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	assumeRoles []string // Role ARNs assumed
+	accessKeys  []string // Access keys used for Converse
+}
+
+func newTenantServer(t *testing.T) *tenantServer {
+	t.Helper()
+	s := &tenantServer{}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if strings.HasSuffix(r.URL.Path, "/converse") {
+			_, credential, _ := strings.Cut(r.Header.Get("Authorization"), "Credential=")
+			accessKey, _, _ := strings.Cut(credential, "/")
+			s.accessKeys = append(s.accessKeys, accessKey)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"output":{"message":{"role":"assistant","content":[{"text":"ok"}]}},` +
+				`"stopReason":"end_turn","usage":{"inputTokens":1,"outputTokens":1,"totalTokens":2}}`))
+			return
+		}
+
+		if err := r.ParseForm(); err != nil || r.Form.Get("Action") != "AssumeRole" {
+			http.NotFound(w, r)
+			return
+		}
+		s.assumeRoles = append(s.assumeRoles, r.Form.Get("RoleArn"))
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>KEY-%s</AccessKeyId>
+      <SecretAccessKey>SECRET</SecretAccessKey>
+      <SessionToken>TOKEN</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+    <AssumedRoleUser>
+      <Arn>%s/session</Arn>
+      <AssumedRoleId>AROA:session</AssumedRoleId>
+    </AssumedRoleUser>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`, r.Form.Get("ExternalId"), time.Now().Add(time.Hour).UTC().Format(time.RFC3339), r.Form.Get("RoleArn"))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestTenantCredentials(t *testing.T) {
+	server := newTenantServer(t)
+	b := &bedrock.Bedrock{
+		AWSConfig: &aws.Config{
+			Region:       "us-east-1",
+			BaseEndpoint: aws.String(server.URL),
+			HTTPClient:   server.Client(),
+			Credentials:  credentials.NewStaticCredentialsProvider("PLUGIN", "SECRET", ""),
+		},
+		RequestRoleARNs: []string{"arn:aws:iam::333333333333:role/*"},
+	}
+	g := newTestPlugin(t, b)
+	m := b.DefineModel(g, bedrock.ModelDefinition{Name: testModel, Type: "chat"}, nil)
+
+	tenantA := bedrock.WithTenantCredentials(context.Background(), bedrock.TenantCredentials{
+		RoleARN:    "arn:aws:iam::111111111111:role/bedrock",
+		ExternalID: "a",
+	})
+	tenantB := bedrock.WithTenantCredentials(context.Background(), bedrock.TenantCredentials{
+		Credentials: credentials.NewStaticCredentialsProvider("KEY-b", "SECRET", ""),
+	})
+	requests := []struct {
+		ctx  context.Context
+		opts []ai.GenerateOption
+	}{
+		{tenantA, nil},
+		{tenantB, nil},
+		{tenantA, nil},
+		{context.Background(), nil},
+		{context.Background(), []ai.GenerateOption{ai.WithConfig(map[string]any{
+			"roleArn":    "arn:aws:iam::333333333333:role/bedrock",
+			"externalId": "c",
+		})}},
+	}
+	for _, req := range requests {
+		opts := append([]ai.GenerateOption{ai.WithModel(m), ai.WithPrompt("hi")}, req.opts...)
+		if _, err := genkit.Generate(req.ctx, g, opts...); err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+	}
+
+	wantKeys := []string{"KEY-a", "KEY-b", "KEY-a", "PLUGIN", "KEY-c"}
+	if fmt.Sprint(server.accessKeys) != fmt.Sprint(wantKeys) {
+		t.Errorf("access keys = %v, want %v", server.accessKeys, wantKeys)
+	}
+	// Tenant A's credentials are cached across requests
+	wantRoles := []string{"arn:aws:iam::111111111111:role/bedrock", "arn:aws:iam::333333333333:role/bedrock"}
+	if fmt.Sprint(server.assumeRoles) != fmt.Sprint(wantRoles) {
+		t.Errorf("assumed roles = %v, want %v", server.assumeRoles, wantRoles)
+	}
+}
+
+func TestRequestRoleNotAllowed(t *testing.T) {
+	server := newTenantServer(t)
+	b := &bedrock.Bedrock{
+		AWSConfig: &aws.Config{
+			Region:       "us-east-1",
+			BaseEndpoint: aws.String(server.URL),
+			HTTPClient:   server.Client(),
+			Credentials:  credentials.NewStaticCredentialsProvider("PLUGIN", "SECRET", ""),
+		},
+		RequestRoleARNs: []string{"arn:aws:iam::333333333333:role/tenant-a"},
+	}
+	g := newTestPlugin(t, b)
+	m := b.DefineModel(g, bedrock.ModelDefinition{Name: testModel, Type: "chat"}, nil)
+
+	_, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithPrompt("hi"),
+		ai.WithConfig(map[string]any{"roleArn": "arn:aws:iam::333333333333:role/tenant-admin"}),
+	)
+	if !errors.Is(err, bedrock.ErrAccessDenied) {
+		t.Fatalf("error = %v, want ErrAccessDenied", err)
+	}
+	if len(server.assumeRoles) != 0 || len(server.accessKeys) != 0 {
+		t.Errorf("assumed roles %v and sent %d requests, want none", server.assumeRoles, len(server.accessKeys))
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/smithy-go"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core"
+	"github.com/firebase/genkit/go/core/api"
+)
+
+// TokenCount is the result of CountTokens.
+type TokenCount struct {
+	InputTokens int  `json:"inputTokens"` // Input tokens of the request
+	Estimated   bool `json:"estimated"`   // Whether the count is a local estimate
+}
+
+// CountTokensInput is the input of the countTokens action.
+type CountTokensInput struct {
+	Model   string           `json:"model"`   // Model ID, with or without the plugin namespace
+	Request *ai.ModelRequest `json:"request"` // Request to count
+}
+
+// CountTokens returns the input tokens the model would process for the request as given,
+// using the Bedrock CountTokens API on its Converse payload after dropping an unsupported
+// assistant prefill and placing cache points, as Generate does. The context window is not
+// fitted, so the count can be used to decide whether to truncate, and no model is called.
+// For models the API doesn't support, the count is estimated locally at about four
+// characters per token.
+func (b *Bedrock) CountTokens(ctx context.Context, modelID string, input *ai.ModelRequest) (*TokenCount, error) {
+	b.mu.Lock()
+	initted := b.initted
+	b.mu.Unlock()
+	if !initted {
+		return nil, errors.New("bedrock: plugin not initialized")
+	}
+	if input == nil {
+		return nil, errors.New("bedrock: a request is required to count tokens")
+	}
+	modelID = strings.TrimPrefix(modelID, b.Name()+"/")
+	ctx, err := b.withRequestTenant(ctx, modelID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	caps, known := b.capabilities(ctx, modelID)
+	input, _ = b.dropUnsupportedPrefill(ctx, modelID, caps, known, input)
+	input = b.placeCachePoints(ctx, modelID, caps, known, input)
+
+	estimate := &TokenCount{InputTokens: estimateInputTokens(input), Estimated: true}
+	baseModel := b.resolveBaseModelID(ctx, modelID)
+	if b.countTokensUnsupported(baseModel) {
+		return estimate, nil
+	}
+
+	converseInput, err := b.buildConverseInput(ctx, modelID, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build converse input: %w", err)
+	}
+	optFns, err := b.runtimeCallOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// CountTokens takes the foundation model, not an inference profile
+	countInput := &bedrockruntime.CountTokensInput{
+		ModelId: aws.String(baseModel),
+		Input: &types.CountTokensInputMemberConverse{
+			Value: types.ConverseTokensRequest{
+				Messages:                     converseInput.Messages,
+				System:                       converseInput.System,
+				ToolConfig:                   converseInput.ToolConfig,
+				AdditionalModelRequestFields: converseInput.AdditionalModelRequestFields,
+			},
+		},
+	}
+	out, _, err := withRegionFailover(ctx, b.regions, b.logger(), func(rc *regionClient) (*bedrockruntime.CountTokensOutput, error) {
+		out, err := rc.client.CountTokens(ctx, countInput, optFns...)
+		if err != nil {
+			return nil, newError("CountTokens", baseModel, err)
+		}
+		return out, nil
+	})
+	if isCountTokensUnsupported(err) {
+		// The model doesn't support token counting, don't ask again
+		b.logger().DebugContext(ctx, "bedrock: CountTokens unsupported, estimating tokens locally", "model", baseModel, "error", err)
+		b.setCountTokensUnsupported(baseModel)
+		return estimate, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &TokenCount{InputTokens: int(aws.ToInt32(out.InputTokens))}, nil
+}
+
+// isCountTokensUnsupported reports whether err is Bedrock rejecting CountTokens for a model
+// that doesn't support it, rather than a problem with the request.
+func isCountTokensUnsupported(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.Is(err, ErrValidation) || !errors.As(err, &apiErr) {
+		return false
+	}
+	msg := strings.ToLower(apiErr.ErrorMessage())
+	for _, s := range []string{
+		"support counting tokens",
+		"support token counting",
+		"counttokens is not supported",
+		"counttokens isn't supported",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// countTokensUnsupported reports whether CountTokens already failed for the model.
+func (b *Bedrock) countTokensUnsupported(modelID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.noCountTokens[modelID]
+}
+
+// setCountTokensUnsupported records that the model doesn't support CountTokens.
+func (b *Bedrock) setCountTokensUnsupported(modelID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.noCountTokens == nil {
+		b.noCountTokens = make(map[string]bool)
+	}
+	b.noCountTokens[modelID] = true
+}
+
+// countTokensAction returns the "<namespace>/countTokens" utility action, which exposes
+// CountTokens in the Genkit Developer UI.
+func (b *Bedrock) countTokensAction() api.Action {
+	return core.NewAction(api.NewName(b.Name(), "countTokens"), api.ActionTypeUtil, nil, nil,
+		func(ctx context.Context, input *CountTokensInput) (*TokenCount, error) {
+			if input == nil || input.Model == "" {
+				return nil, errors.New("bedrock: countTokens needs a model")
+			}
+			return b.CountTokens(ctx, input.Model, input.Request)
+		})
+}
@@ -0,0 +1,164 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/firebase/genkit/go/ai"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+	"github.com/xavidop/genkit-aws-bedrock-go/bedrocktest"
+)
+
+func TestCountTokens(t *testing.T) {
+	fake := bedrocktest.NewClient().AddCountTokens(42)
+	b := &bedrock.Bedrock{Client: fake}
+	newTestPlugin(t, b)
+
+	req := &ai.ModelRequest{Messages: []*ai.Message{
+		ai.NewSystemTextMessage("Be brief."),
+		ai.NewUserTextMessage("How many tokens is this?"),
+	}}
+	count, err := b.CountTokens(context.Background(), "bedrock/us."+testModel, req)
+	if err != nil {
+		t.Fatalf("CountTokens: %v", err)
+	}
+	if count.InputTokens != 42 || count.Estimated {
+		t.Errorf("CountTokens = %+v, want 42 counted tokens", count)
+	}
+
+	inputs := fake.CountTokensInputs()
+	if len(inputs) != 1 {
+		t.Fatalf("got %d CountTokens calls, want 1", len(inputs))
+	}
+	// The inference profile is counted with its foundation model
+	if got := aws.ToString(inputs[0].ModelId); got != testModel {
+		t.Errorf("ModelId = %q, want %q", got, testModel)
+	}
+	converse, ok := inputs[0].Input.(*types.CountTokensInputMemberConverse)
+	if !ok {
+		t.Fatalf("Input = %T, want a Converse request", inputs[0].Input)
+	}
+	if len(converse.Value.System) != 1 || len(converse.Value.Messages) != 1 {
+		t.Errorf("Converse request has %d system blocks and %d messages, want 1 and 1",
+			len(converse.Value.System), len(converse.Value.Messages))
+	}
+}
+
+func TestCountTokensEstimate(t *testing.T) {
+	unsupported := &types.ValidationException{Message: aws.String("The provided model doesn't support counting tokens.")}
+	fake := bedrocktest.NewClient().AddCountTokensError(unsupported)
+	b := &bedrock.Bedrock{Client: fake}
+	newTestPlugin(t, b)
+
+	req := &ai.ModelRequest{Messages: []*ai.Message{ai.NewUserTextMessage("0123456789abcdef")}}
+	for range 2 {
+		count, err := b.CountTokens(context.Background(), "amazon.titan-text-express-v1", req)
+		if err != nil {
+			t.Fatalf("CountTokens: %v", err)
+		}
+		if count.InputTokens != 4 || !count.Estimated {
+			t.Errorf("CountTokens = %+v, want an estimate of 4 tokens", count)
+		}
+	}
+	// The unsupported model isn't sent to CountTokens again
+	if n := len(fake.CountTokensInputs()); n != 1 {
+		t.Errorf("got %d CountTokens calls, want 1", n)
+	}
+}
+
+func TestCountTokensRequestError(t *testing.T) {
+	invalid := &types.ValidationException{Message: aws.String("messages: text content blocks must be non-empty")}
+	fake := bedrocktest.NewClient().AddCountTokensError(invalid).AddCountTokens(7)
+	b := &bedrock.Bedrock{Client: fake}
+	newTestPlugin(t, b)
+
+	req := &ai.ModelRequest{Messages: []*ai.Message{ai.NewUserTextMessage("hi")}}
+	if _, err := b.CountTokens(context.Background(), testModel, req); !errors.Is(err, bedrock.ErrValidation) {
+		t.Fatalf("CountTokens error = %v, want the validation error", err)
+	}
+
+	// A rejected request doesn't mark the model as unsupported
+	count, err := b.CountTokens(context.Background(), testModel, req)
+	if err != nil {
+		t.Fatalf("CountTokens: %v", err)
+	}
+	if count.InputTokens != 7 || count.Estimated {
+		t.Errorf("CountTokens = %+v, want 7 counted tokens", count)
+	}
+}
+
+func TestCountTokensPreparesRequest(t *testing.T) {
+	fake := bedrocktest.NewClient().AddCountTokens(42).AddCountTokens(1500).AddCountTokens(300)
+	b := &bedrock.Bedrock{
+		Client:        fake,
+		CachePolicy:   &bedrock.CachePolicy{System: true},
+		ContextWindow: &bedrock.ContextWindow{Strategy: bedrock.ContextStrategySummarize, MaxInputTokens: 100},
+	}
+	newTestPlugin(t, b)
+
+	// The assistant prefill is dropped for a model without prefill support
+	prefilled := &ai.ModelRequest{Messages: []*ai.Message{
+		ai.NewUserTextMessage("Name a color."),
+		ai.NewModelTextMessage("The color is"),
+	}}
+	if _, err := b.CountTokens(context.Background(), "meta.llama3-8b-instruct-v1:0", prefilled); err != nil {
+		t.Fatalf("CountTokens: %v", err)
+	}
+
+	// The cache policy places a cache point after a long system prompt
+	cached := &ai.ModelRequest{Messages: []*ai.Message{
+		ai.NewSystemTextMessage(strings.Repeat("You are a helpful assistant. ", 600)),
+		ai.NewUserTextMessage("hi"),
+	}}
+	if _, err := b.CountTokens(context.Background(), testModel, cached); err != nil {
+		t.Fatalf("CountTokens: %v", err)
+	}
+
+	// The history is counted as given, without fitting the context window
+	history := &ai.ModelRequest{Messages: longHistory()}
+	if _, err := b.CountTokens(context.Background(), testModel, history); err != nil {
+		t.Fatalf("CountTokens: %v", err)
+	}
+
+	inputs := fake.CountTokensInputs()
+	if len(inputs) != 3 {
+		t.Fatalf("got %d CountTokens calls, want 3", len(inputs))
+	}
+	if messages := inputs[0].Input.(*types.CountTokensInputMemberConverse).Value.Messages; len(messages) != 1 {
+		t.Errorf("counted %d messages, want the prefill dropped", len(messages))
+	}
+	system := inputs[1].Input.(*types.CountTokensInputMemberConverse).Value.System
+	if len(system) != 2 {
+		t.Fatalf("counted %d system blocks, want the text and a cache point", len(system))
+	}
+	if _, ok := system[1].(*types.SystemContentBlockMemberCachePoint); !ok {
+		t.Errorf("last system block = %T, want a cache point", system[1])
+	}
+	messages := inputs[2].Input.(*types.CountTokensInputMemberConverse).Value.Messages
+	if text, ok := messages[0].Content[0].(*types.ContentBlockMemberText); !ok || !strings.HasPrefix(text.Value, "first ") {
+		t.Errorf("first counted block = %#v, want the oldest turn kept", messages[0].Content[0])
+	}
+	if n := len(fake.ConverseInputs()); n != 0 {
+		t.Errorf("got %d Converse calls, want no summary requested", n)
+	}
+}
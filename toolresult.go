@@ -0,0 +1,303 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/firebase/genkit/go/ai"
+)
+
+// toolErrorKey is the part metadata key marking a tool response as a failed tool call.
+const toolErrorKey = "toolError"
+
+// NewToolErrorResponsePart returns a tool response part reporting that a tool call failed.
+// The error message is sent as the tool result, with an error status for models that support it,
+// so the model can recover, e.g. by retrying with other input.
+func NewToolErrorResponsePart(req *ai.ToolRequest, err error) *ai.Part {
+	part := ai.NewToolResponsePart(&ai.ToolResponse{Name: req.Name, Ref: req.Ref, Output: err.Error()})
+	part.Metadata = map[string]any{toolErrorKey: true}
+	return part
+}
+
+// NewMultipartToolResponsePart returns a tool response part made of text and media parts, e.g. a
+// screenshot with a caption. Tools can also return []*ai.Part as their output.
+func NewMultipartToolResponsePart(req *ai.ToolRequest, parts ...*ai.Part) *ai.Part {
+	return ai.NewToolResponsePart(&ai.ToolResponse{Name: req.Name, Ref: req.Ref, Output: parts})
+}
+
+// IsToolError reports whether a tool response part reports a failed tool call.
+func IsToolError(part *ai.Part) bool {
+	if part == nil || !part.IsToolResponse() {
+		return false
+	}
+	if isError, _ := part.Metadata[toolErrorKey].(bool); isError {
+		return true
+	}
+	_, isError := part.ToolResponse.Output.(error)
+	return isError
+}
+
+// toolResultBlock converts a tool response part to a Bedrock tool result. Object outputs, media
+// and error status use the blocks the model supports according to the catalog, anything else is
+// sent as text.
+func (b *Bedrock) toolResultBlock(ctx context.Context, modelName string, caps ModelCapabilities, part *ai.Part) *types.ContentBlockMemberToolResult {
+	var content []types.ToolResultContentBlock
+	switch output := part.ToolResponse.Output.(type) {
+	case nil:
+	case string:
+		content = append(content, &types.ToolResultContentBlockMemberText{Value: output})
+	case error:
+		content = append(content, &types.ToolResultContentBlockMemberText{Value: output.Error()})
+	case *ai.Part:
+		content = b.toolResultParts(ctx, modelName, caps, []*ai.Part{output})
+	case []*ai.Part:
+		content = b.toolResultParts(ctx, modelName, caps, output)
+	default:
+		content = append(content, toolResultValue(caps, output))
+	}
+
+	status := types.ToolResultStatusSuccess
+	if caps.ToolResultStatus && IsToolError(part) {
+		status = types.ToolResultStatusError
+	}
+	return &types.ContentBlockMemberToolResult{
+		Value: types.ToolResultBlock{
+			ToolUseId: aws.String(part.ToolResponse.Ref),
+			Content:   content,
+			Status:    status,
+		},
+	}
+}
+
+// toolResultValue converts a tool output value to a JSON block if it is an object and the model
+// accepts JSON tool results, or to its JSON text otherwise.
+func toolResultValue(caps ModelCapabilities, output any) types.ToolResultContentBlock {
+	data, err := json.Marshal(output)
+	if err != nil {
+		return &types.ToolResultContentBlockMemberText{Value: fmt.Sprintf("%v", output)}
+	}
+	if caps.SupportsToolResult(ToolResultJSON) {
+		// Documents are built from the decoded JSON, so that struct fields keep their json tags
+		var object map[string]any
+		if err := json.Unmarshal(data, &object); err == nil && object != nil {
+			return &types.ToolResultContentBlockMemberJson{Value: document.NewLazyDocument(object)}
+		}
+	}
+	return &types.ToolResultContentBlockMemberText{Value: string(data)}
+}
+
+// toolResultParts converts the parts of a multipart tool response. Media the model doesn't accept
+// in tool results is dropped with a warning.
+func (b *Bedrock) toolResultParts(ctx context.Context, modelName string, caps ModelCapabilities, parts []*ai.Part) []types.ToolResultContentBlock {
+	var content []types.ToolResultContentBlock
+	documents := 0
+	for _, part := range parts {
+		switch {
+		case part == nil:
+		case part.IsText():
+			content = append(content, &types.ToolResultContentBlockMemberText{Value: part.Text})
+		case part.IsData():
+			var value any
+			if err := json.Unmarshal([]byte(part.Text), &value); err != nil {
+				content = append(content, &types.ToolResultContentBlockMemberText{Value: part.Text})
+				continue
+			}
+			content = append(content, toolResultValue(caps, value))
+		case part.IsMedia():
+			mediaType, data := mediaPartData(part)
+			block, err := toolResultMedia(caps, mediaType, data, &documents)
+			if err != nil {
+				b.logger().WarnContext(ctx, "bedrock: ignoring media in tool result", "model", modelName, "contentType", mediaType, "error", err)
+				continue
+			}
+			content = append(content, block)
+		default:
+			b.logger().WarnContext(ctx, "bedrock: ignoring unsupported part in tool result", "model", modelName, "kind", part.Kind)
+		}
+	}
+	return content
+}
+
+// errUnsupportedToolResult reports media the model doesn't accept in tool results.
+var errUnsupportedToolResult = errors.New("the model does not support this content in tool results")
+
+// toolResultMedia converts media to an image, video or document block. documents counts the
+// documents of the tool result, which need unique names.
+func toolResultMedia(caps ModelCapabilities, mediaType string, data []byte, documents *int) (types.ToolResultContentBlock, error) {
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		if !caps.SupportsToolResult(ToolResultImage) {
+			return nil, errUnsupportedToolResult
+		}
+		return &types.ToolResultContentBlockMemberImage{Value: types.ImageBlock{
+			Format: imageFormat(mediaType),
+			Source: &types.ImageSourceMemberBytes{Value: data},
+		}}, nil
+	case strings.HasPrefix(mediaType, "video/"):
+		format, ok := videoFormats[mediaType]
+		if !ok {
+			return nil, errors.New("unsupported video format")
+		}
+		if !caps.SupportsToolResult(ToolResultVideo) {
+			return nil, errUnsupportedToolResult
+		}
+		return &types.ToolResultContentBlockMemberVideo{Value: types.VideoBlock{
+			Format: format,
+			Source: &types.VideoSourceMemberBytes{Value: data},
+		}}, nil
+	default:
+		format, ok := documentFormats[mediaType]
+		if !ok {
+			return nil, errors.New("unsupported document format")
+		}
+		if !caps.SupportsToolResult(ToolResultDocument) {
+			return nil, errUnsupportedToolResult
+		}
+		*documents++
+		return &types.ToolResultContentBlockMemberDocument{Value: types.DocumentBlock{
+			Format: format,
+			Name:   aws.String(fmt.Sprintf("document-%d", *documents)),
+			Source: &types.DocumentSourceMemberBytes{Value: data},
+		}}, nil
+	}
+}
+
+// mediaBlock converts media in a message to an image, video or document block, by MIME type.
+// Media of other types, or without a type, is sent as an image. documents counts the documents
+// of the conversation, which need unique names.
+func mediaBlock(mediaType string, data []byte, documents *int) (types.ContentBlock, error) {
+	switch {
+	case strings.HasPrefix(mediaType, "video/"):
+		format, ok := videoFormats[mediaType]
+		if !ok {
+			return nil, fmt.Errorf("unsupported video format %q", mediaType)
+		}
+		return &types.ContentBlockMemberVideo{Value: types.VideoBlock{
+			Format: format,
+			Source: &types.VideoSourceMemberBytes{Value: data},
+		}}, nil
+	case isDocumentType(mediaType):
+		*documents++
+		return &types.ContentBlockMemberDocument{Value: types.DocumentBlock{
+			Format: documentFormats[mediaType],
+			Name:   aws.String(fmt.Sprintf("attachment-%d", *documents)),
+			Source: &types.DocumentSourceMemberBytes{Value: data},
+		}}, nil
+	default:
+		return &types.ContentBlockMemberImage{Value: types.ImageBlock{
+			Format: imageFormat(mediaType),
+			Source: &types.ImageSourceMemberBytes{Value: data},
+		}}, nil
+	}
+}
+
+// isDocumentType reports whether media of the MIME type is sent as a document.
+func isDocumentType(mediaType string) bool {
+	_, ok := documentFormats[mediaType]
+	return ok
+}
+
+// documentFormats maps MIME types to Bedrock document formats.
+var documentFormats = map[string]types.DocumentFormat{
+	"application/pdf":          types.DocumentFormatPdf,
+	"application/msword":       types.DocumentFormatDoc,
+	"application/vnd.ms-excel": types.DocumentFormatXls,
+	"text/csv":                 types.DocumentFormatCsv,
+	"text/html":                types.DocumentFormatHtml,
+	"text/plain":               types.DocumentFormatTxt,
+	"text/markdown":            types.DocumentFormatMd,
+
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": types.DocumentFormatDocx,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":       types.DocumentFormatXlsx,
+}
+
+// videoFormats maps MIME types to Bedrock video formats.
+var videoFormats = map[string]types.VideoFormat{
+	"video/mp4":        types.VideoFormatMp4,
+	"video/quicktime":  types.VideoFormatMov,
+	"video/webm":       types.VideoFormatWebm,
+	"video/x-matroska": types.VideoFormatMkv,
+	"video/x-flv":      types.VideoFormatFlv,
+	"video/mpeg":       types.VideoFormatMpeg,
+	"video/x-ms-wmv":   types.VideoFormatWmv,
+	"video/3gpp":       types.VideoFormatThreeGp,
+}
+
+// imageFormat returns the Bedrock image format for a MIME type, defaulting to PNG.
+func imageFormat(mediaType string) types.ImageFormat {
+	switch mediaType {
+	case "image/jpeg", "image/jpg":
+		return types.ImageFormatJpeg
+	case "image/gif":
+		return types.ImageFormatGif
+	case "image/webp":
+		return types.ImageFormatWebp
+	default:
+		return types.ImageFormatPng
+	}
+}
+
+// mediaPartType returns the MIME type of a media part, taking it from the data URL if the part
+// has none.
+func mediaPartType(part *ai.Part) string {
+	if part.ContentType != "" || !strings.HasPrefix(part.Text, "data:") {
+		return part.ContentType
+	}
+	// Data URL format: data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAA...
+	header, _, _ := strings.Cut(part.Text, ",")
+	mediaType, _, _ := strings.Cut(strings.TrimPrefix(header, "data:"), ";")
+	return mediaType
+}
+
+// mediaPartData returns the MIME type and content of a media part. Data URLs are decoded; other
+// content is base64 decoded if possible and used as is otherwise.
+func mediaPartData(part *ai.Part) (string, []byte) {
+	content := part.Text
+	if strings.HasPrefix(content, "data:") {
+		if _, data, ok := strings.Cut(content, ","); ok {
+			content = data
+		}
+	}
+	data, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		data = []byte(content)
+	}
+	return mediaPartType(part), data
+}
+
+// clearToolResultStatus reports failed tool results as successful, for models that reject the
+// error status. The result content still describes the failure.
+func clearToolResultStatus(messages []types.Message) {
+	for _, msg := range messages {
+		for i, block := range msg.Content {
+			if result, ok := block.(*types.ContentBlockMemberToolResult); ok && result.Value.Status == types.ToolResultStatusError {
+				cleared := *result
+				cleared.Value.Status = types.ToolResultStatusSuccess
+				msg.Content[i] = &cleared
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2025 Xavier Portilla Edo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bedrock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	bedrock "github.com/xavidop/genkit-aws-bedrock-go"
+	"github.com/xavidop/genkit-aws-bedrock-go/bedrocktest"
+)
+
+// toolResults sends the tool responses after a model turn requesting them and returns the tool
+// results received by Bedrock.
+func toolResults(t *testing.T, modelID string, responses ...*ai.Part) []types.ToolResultBlock {
+	t.Helper()
+	fake := bedrocktest.NewClient().AddConverse(bedrocktest.TextOutput("Done."))
+	g, m := defineTestModel(t, fake, modelID)
+
+	var requests []*ai.Part
+	for _, resp := range responses {
+		requests = append(requests, toolRequest(resp.ToolResponse.Ref))
+	}
+	_, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithMessages(
+			ai.NewUserTextMessage("Check the weather"),
+			ai.NewModelMessage(requests...),
+			ai.NewMessage(ai.RoleTool, nil, responses...),
+		),
+	)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	var results []types.ToolResultBlock
+	for _, block := range fake.ConverseInputs()[0].Messages[2].Content {
+		if result, ok := block.(*types.ContentBlockMemberToolResult); ok {
+			results = append(results, result.Value)
+		}
+	}
+	if len(results) != len(responses) {
+		t.Fatalf("got %d tool results, want %d", len(results), len(responses))
+	}
+	return results
+}
+
+type forecast struct {
+	City        string `json:"city"`
+	Temperature int    `json:"temperature"`
+}
+
+func TestRichToolResults(t *testing.T) {
+	req := func(ref string) *ai.ToolRequest { return &ai.ToolRequest{Name: "getWeather", Ref: ref} }
+	results := toolResults(t, testModel,
+		ai.NewToolResponsePart(&ai.ToolResponse{Name: "getWeather", Ref: "t1", Output: forecast{"Paris", 21}}),
+		bedrock.NewMultipartToolResponsePart(req("t2"),
+			ai.NewTextPart("Radar and report attached."),
+			ai.NewMediaPart("image/png", "data:image/png;base64,iVBORw0KGgo="),
+			ai.NewMediaPart("application/pdf", "JVBERi0xLjQ="),
+		),
+		bedrock.NewToolErrorResponsePart(req("t3"), errors.New("weather service unavailable")),
+	)
+
+	json, ok := results[0].Content[0].(*types.ToolResultContentBlockMemberJson)
+	if !ok {
+		t.Fatalf("object output = %#v, want a JSON block", results[0].Content[0])
+	}
+	data, err := json.Value.MarshalSmithyDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != `{"city":"Paris","temperature":21}` {
+		t.Errorf("JSON output = %s, want the json field names", got)
+	}
+
+	content := results[1].Content
+	if len(content) != 3 {
+		t.Fatalf("got %d blocks in the multipart result, want 3", len(content))
+	}
+	if _, ok := content[0].(*types.ToolResultContentBlockMemberText); !ok {
+		t.Errorf("block 0 = %#v, want text", content[0])
+	}
+	if image, ok := content[1].(*types.ToolResultContentBlockMemberImage); !ok || image.Value.Format != types.ImageFormatPng {
+		t.Errorf("block 1 = %#v, want a PNG image", content[1])
+	}
+	if doc, ok := content[2].(*types.ToolResultContentBlockMemberDocument); !ok || doc.Value.Format != types.DocumentFormatPdf {
+		t.Errorf("block 2 = %#v, want a PDF document", content[2])
+	}
+
+	if results[1].Status != types.ToolResultStatusSuccess {
+		t.Errorf("multipart result status = %q, want success", results[1].Status)
+	}
+	if results[2].Status != types.ToolResultStatusError {
+		t.Errorf("failed tool status = %q, want error", results[2].Status)
+	}
+	if text, ok := results[2].Content[0].(*types.ToolResultContentBlockMemberText); !ok || text.Value != "weather service unavailable" {
+		t.Errorf("failed tool content = %#v, want the error message", results[2].Content[0])
+	}
+}
+
+func TestRichToolResultsUnsupported(t *testing.T) {
+	req := &ai.ToolRequest{Name: "getWeather", Ref: "t2"}
+	results := toolResults(t, "ai21.jamba-1-5-mini-v1:0",
+		ai.NewToolResponsePart(&ai.ToolResponse{Name: "getWeather", Ref: "t1", Output: forecast{"Paris", 21}}),
+		bedrock.NewMultipartToolResponsePart(req,
+			ai.NewTextPart("Radar attached."),
+			ai.NewMediaPart("image/png", "data:image/png;base64,iVBORw0KGgo="),
+		),
+		bedrock.NewToolErrorResponsePart(&ai.ToolRequest{Name: "getWeather", Ref: "t3"}, errors.New("unavailable")),
+	)
+
+	if text, ok := results[0].Content[0].(*types.ToolResultContentBlockMemberText); !ok || text.Value != `{"city":"Paris","temperature":21}` {
+		t.Errorf("object output = %#v, want JSON text", results[0].Content[0])
+	}
+	if n := len(results[1].Content); n != 1 {
+		t.Errorf("got %d blocks in the multipart result, want the image dropped", n)
+	}
+	if results[2].Status != types.ToolResultStatusSuccess {
+		t.Errorf("failed tool status = %q, want success for a model without error status", results[2].Status)
+	}
+}